@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"io"
 	"net/http"
 	"time"
 )
@@ -10,9 +11,40 @@ type Store interface {
 	Get(key string) (*ResponseCacheEntry, bool)
 	Set(key string, entry *ResponseCacheEntry) error
 	Delete(key string) error
+	// DeleteByTag removes every entry carrying tag in its ResponseCacheEntry.Tags.
+	DeleteByTag(tag string) error
+	// Purge removes every entry whose key starts with prefix.
+	Purge(prefix string) error
+
+	// BeginWrite opens a BlobWriter for key so a response body can be
+	// streamed into the store as it's produced, instead of being buffered
+	// in full beforehand. meta carries everything but Body, which the
+	// writer fills in incrementally; Commit finalizes the entry, Abort
+	// discards it.
+	BeginWrite(key string, meta ResponseCacheEntry) (BlobWriter, error)
+	// OpenReader returns the stored metadata for key (with Body left zero)
+	// alongside a BlobReader that streams the body, so a hit can be copied
+	// straight to the client without materializing it in memory.
+	OpenReader(key string) (ResponseCacheEntry, BlobReader, bool)
+
 	Close() error // For graceful shutdown/cleanup
 }
 
+// BlobWriter spools a response body into a Store. Callers must call exactly
+// one of Commit or Abort when done.
+type BlobWriter interface {
+	io.Writer
+	// Commit finalizes the write, making it visible to Get/OpenReader.
+	Commit() error
+	// Abort discards everything written so far.
+	Abort() error
+}
+
+// BlobReader streams a stored response body back out.
+type BlobReader interface {
+	io.ReadCloser
+}
+
 // ResponseCacheEntry holds the complete HTTP response data and caching metadata.
 type ResponseCacheEntry struct {
 	StatusCode int
@@ -21,6 +53,28 @@ type ResponseCacheEntry struct {
 	CreatedAt  time.Time
 	TTL        time.Duration // Time-to-Live (Freshness)
 	SWR        time.Duration // Stale-While-Revalidate window
+
+	// ETag and LastModified are carried over from the origin response so a
+	// stale entry can be conditionally revalidated with If-None-Match /
+	// If-Modified-Since instead of being re-fetched in full.
+	ETag         string
+	LastModified string
+
+	// StaleIfError is how long past TTL+SWR a rotten entry may still be
+	// served if revalidation fails (RFC 7234 stale-if-error). Zero disables it.
+	StaleIfError time.Duration
+
+	// Vary lists the response header names (from the origin's Vary header)
+	// that this entry's representation depends on. VaryHeaders holds the
+	// values of those headers as seen on the request that produced this
+	// entry, so a later request can be checked for a match before reuse.
+	Vary        []string
+	VaryHeaders map[string]string
+
+	// Tags are invalidation groups (e.g. "user:42", "post:7") attached at
+	// cache-write time via Config.TagExtractor, so a mutation elsewhere can
+	// invalidate every entry in a group with DeleteByTag.
+	Tags []string
 }
 
 // Size returns the estimated memory footprint in bytes.
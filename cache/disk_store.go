@@ -0,0 +1,337 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DiskStore is a disk-backed Store: each entry is written as a pair of files
+// under dir, "<hash>.meta" (gob-encoded ResponseCacheEntry with Body omitted)
+// and "<hash>.body" (the raw bytes). Reads and writes stream through the
+// filesystem instead of holding the whole body in memory, so a single
+// instance can cache multi-MB responses without OOMing. An LRU index of
+// keys/sizes/tags is kept in memory and persisted to "index.gob" on Close so
+// it survives a restart.
+type DiskStore struct {
+	mutex sync.Mutex
+	dir   string
+
+	maxBytes     int64
+	currentBytes int64
+	lru          *list.List
+	index        map[string]*list.Element
+	tagIndex     map[string]map[string]struct{}
+}
+
+// diskIndexEntry is the in-memory (and persisted) bookkeeping record for one
+// cached key; it mirrors lruEntry in in_memory_quota_lru.go but points at
+// files on disk rather than bytes in memory.
+type diskIndexEntry struct {
+	Key  string
+	Hash string
+	Size int64
+	Tags []string
+}
+
+// NewDiskStore opens (or creates) dir as a disk-backed Store, loading any
+// index persisted by a previous clean Close.
+func NewDiskStore(dir string, maxBytes int64) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("disk store: creating %s: %w", dir, err)
+	}
+
+	store := &DiskStore{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+		tagIndex: make(map[string]map[string]struct{}),
+	}
+	if err := store.loadIndex(); err != nil {
+		return nil, fmt.Errorf("disk store: loading index: %w", err)
+	}
+	return store, nil
+}
+
+func (s *DiskStore) indexPath() string {
+	return filepath.Join(s.dir, "index.gob")
+}
+
+func (s *DiskStore) loadIndex() error {
+	f, err := os.Open(s.indexPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []diskIndexEntry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		element := s.lru.PushBack(entry)
+		s.index[entry.Key] = element
+		s.currentBytes += entry.Size
+		s.addToTagIndexLocked(entry.Key, entry.Tags)
+	}
+	return nil
+}
+
+// Close persists the current LRU index so it survives a restart.
+func (s *DiskStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := make([]diskIndexEntry, 0, s.lru.Len())
+	for element := s.lru.Front(); element != nil; element = element.Next() {
+		entries = append(entries, element.Value.(diskIndexEntry))
+	}
+
+	f, err := os.Create(s.indexPath())
+	if err != nil {
+		return fmt.Errorf("disk store: persisting index: %w", err)
+	}
+	defer f.Close()
+	return gob.NewEncoder(f).Encode(entries)
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *DiskStore) metaPath(hash string) string { return filepath.Join(s.dir, hash+".meta") }
+func (s *DiskStore) bodyPath(hash string) string { return filepath.Join(s.dir, hash+".body") }
+
+// Get reads an entry's metadata and body fully into memory. Prefer
+// OpenReader for large bodies.
+func (s *DiskStore) Get(key string) (*ResponseCacheEntry, bool) {
+	meta, reader, ok := s.OpenReader(key)
+	if !ok {
+		return nil, false
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false
+	}
+	meta.Body = body
+	return &meta, true
+}
+
+// OpenReader returns key's metadata (Body left nil) and a file handle
+// streaming its body.
+func (s *DiskStore) OpenReader(key string) (ResponseCacheEntry, BlobReader, bool) {
+	s.mutex.Lock()
+	element, ok := s.index[key]
+	if ok {
+		s.lru.MoveToFront(element)
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return ResponseCacheEntry{}, nil, false
+	}
+	hash := element.Value.(diskIndexEntry).Hash
+
+	metaFile, err := os.Open(s.metaPath(hash))
+	if err != nil {
+		return ResponseCacheEntry{}, nil, false
+	}
+	defer metaFile.Close()
+
+	var meta ResponseCacheEntry
+	if err := gob.NewDecoder(metaFile).Decode(&meta); err != nil {
+		return ResponseCacheEntry{}, nil, false
+	}
+
+	bodyFile, err := os.Open(s.bodyPath(hash))
+	if err != nil {
+		return ResponseCacheEntry{}, nil, false
+	}
+	return meta, bodyFile, true
+}
+
+// Set writes entry to disk in one shot.
+func (s *DiskStore) Set(key string, entry *ResponseCacheEntry) error {
+	writer, err := s.BeginWrite(key, *entry)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(entry.Body); err != nil {
+		_ = writer.Abort()
+		return err
+	}
+	return writer.Commit()
+}
+
+// BeginWrite spools a new body into a temp file, so a large response
+// streams straight to disk instead of being buffered in memory first.
+func (s *DiskStore) BeginWrite(key string, meta ResponseCacheEntry) (BlobWriter, error) {
+	tmp, err := os.CreateTemp(s.dir, "blob-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("disk store: creating temp file for %q: %w", key, err)
+	}
+	meta.Body = nil
+	return &diskBlobWriter{store: s, key: key, meta: meta, tmp: tmp}, nil
+}
+
+type diskBlobWriter struct {
+	store *DiskStore
+	key   string
+	meta  ResponseCacheEntry
+	tmp   *os.File
+	size  int64
+}
+
+func (w *diskBlobWriter) Write(p []byte) (int, error) {
+	n, err := w.tmp.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *diskBlobWriter) Commit() error {
+	defer os.Remove(w.tmp.Name())
+	if err := w.tmp.Close(); err != nil {
+		return err
+	}
+
+	hash := hashKey(w.key)
+	if err := os.Rename(w.tmp.Name(), w.store.bodyPath(hash)); err != nil {
+		return fmt.Errorf("disk store: committing body for %q: %w", w.key, err)
+	}
+
+	metaFile, err := os.Create(w.store.metaPath(hash))
+	if err != nil {
+		return fmt.Errorf("disk store: writing meta for %q: %w", w.key, err)
+	}
+	defer metaFile.Close()
+	if err := gob.NewEncoder(metaFile).Encode(w.meta); err != nil {
+		return fmt.Errorf("disk store: encoding meta for %q: %w", w.key, err)
+	}
+
+	w.store.record(w.key, hash, w.size, w.meta.Tags)
+	return nil
+}
+
+func (w *diskBlobWriter) Abort() error {
+	_ = w.tmp.Close()
+	return os.Remove(w.tmp.Name())
+}
+
+// record updates the LRU index after a successful commit and evicts the
+// least-recently-used entries until back under maxBytes.
+func (s *DiskStore) record(key, hash string, size int64, tags []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.index[key]; ok {
+		old := element.Value.(diskIndexEntry)
+		s.currentBytes -= old.Size
+		s.removeFromTagIndexLocked(key, old.Tags)
+		element.Value = diskIndexEntry{Key: key, Hash: hash, Size: size, Tags: tags}
+		s.lru.MoveToFront(element)
+	} else {
+		element := s.lru.PushFront(diskIndexEntry{Key: key, Hash: hash, Size: size, Tags: tags})
+		s.index[key] = element
+	}
+	s.currentBytes += size
+	s.addToTagIndexLocked(key, tags)
+
+	for s.currentBytes > s.maxBytes {
+		back := s.lru.Back()
+		if back == nil {
+			break
+		}
+		s.evictLocked(back)
+	}
+}
+
+// evictLocked removes element from the index and its files from disk.
+// Callers must hold s.mutex.
+func (s *DiskStore) evictLocked(element *list.Element) {
+	entry := s.lru.Remove(element).(diskIndexEntry)
+	delete(s.index, entry.Key)
+	s.currentBytes -= entry.Size
+	s.removeFromTagIndexLocked(entry.Key, entry.Tags)
+	_ = os.Remove(s.metaPath(entry.Hash))
+	_ = os.Remove(s.bodyPath(entry.Hash))
+}
+
+// Delete removes key's files and index entry, if present.
+func (s *DiskStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.index[key]
+	if !ok {
+		return nil
+	}
+	s.evictLocked(element)
+	return nil
+}
+
+// DeleteByTag removes every entry carrying tag, via the in-memory tag index.
+func (s *DiskStore) DeleteByTag(tag string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys, ok := s.tagIndex[tag]
+	if !ok {
+		return nil
+	}
+	for key := range keys {
+		if element, ok := s.index[key]; ok {
+			s.evictLocked(element)
+		}
+	}
+	return nil
+}
+
+// Purge removes every entry whose key starts with prefix.
+func (s *DiskStore) Purge(prefix string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, element := range s.index {
+		if strings.HasPrefix(key, prefix) {
+			s.evictLocked(element)
+		}
+	}
+	return nil
+}
+
+func (s *DiskStore) addToTagIndexLocked(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := s.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tagIndex[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (s *DiskStore) removeFromTagIndexLocked(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := s.tagIndex[tag]
+		if !ok {
+			continue
+		}
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(s.tagIndex, tag)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package cache
+
+import "testing"
+
+func TestDiskStoreSetGetDelete(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("k", &ResponseCacheEntry{StatusCode: 200, Body: []byte("hello")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, ok := store.Get("k")
+	if !ok {
+		t.Fatalf("expected hit after Set")
+	}
+	if string(got.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", got.Body)
+	}
+
+	if err := store.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := store.Get("k"); ok {
+		t.Fatalf("expected miss after Delete")
+	}
+}
+
+func TestDiskStoreBeginWriteStreamsAndOpenReaderOmitsBody(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer store.Close()
+
+	writer, err := store.BeginWrite("k", ResponseCacheEntry{StatusCode: 200})
+	if err != nil {
+		t.Fatalf("BeginWrite: %v", err)
+	}
+	if _, err := writer.Write([]byte("chunk-one-")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := writer.Write([]byte("chunk-two")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	meta, reader, ok := store.OpenReader("k")
+	if !ok {
+		t.Fatalf("expected hit after Commit")
+	}
+	defer reader.Close()
+	if meta.Body != nil {
+		t.Fatalf("expected OpenReader metadata to omit Body, got %q", meta.Body)
+	}
+
+	body := make([]byte, 19)
+	if _, err := reader.Read(body); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(body) != "chunk-one-chunk-two" {
+		t.Fatalf("unexpected streamed body: %s", body)
+	}
+}
+
+func TestDiskStoreIndexSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewDiskStore(dir, 1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := store.Set("k", &ResponseCacheEntry{StatusCode: 200, Body: []byte("hello")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewDiskStore(dir, 1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore (reopen): %v", err)
+	}
+	defer reopened.Close()
+
+	got, ok := reopened.Get("k")
+	if !ok {
+		t.Fatalf("expected entry to survive a Close/NewDiskStore cycle")
+	}
+	if string(got.Body) != "hello" {
+		t.Fatalf("unexpected body after reopen: %s", got.Body)
+	}
+}
+
+func TestDiskStoreDeleteByTagAndPurge(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("cache:GET:/users/1", &ResponseCacheEntry{Body: []byte("a"), Tags: []string{"user:1", "tenant:7"}})
+	store.Set("cache:GET:/users/2", &ResponseCacheEntry{Body: []byte("b"), Tags: []string{"user:2", "tenant:7"}})
+	store.Set("cache:GET:/posts/1", &ResponseCacheEntry{Body: []byte("c"), Tags: []string{"post:1"}})
+
+	if err := store.DeleteByTag("tenant:7"); err != nil {
+		t.Fatalf("DeleteByTag: %v", err)
+	}
+	if _, ok := store.Get("cache:GET:/users/1"); ok {
+		t.Fatalf("expected /users/1 to be purged by tag")
+	}
+	if _, ok := store.Get("cache:GET:/posts/1"); !ok {
+		t.Fatalf("expected /posts/1 to survive an unrelated tag purge")
+	}
+
+	if err := store.Purge("cache:GET:/posts/"); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if _, ok := store.Get("cache:GET:/posts/1"); ok {
+		t.Fatalf("expected /posts/1 to be purged by prefix")
+	}
+}
+
+func TestDiskStoreEvictsOverQuota(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 6)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("a", &ResponseCacheEntry{Body: []byte("first")})
+	store.Set("b", &ResponseCacheEntry{Body: []byte("second")})
+
+	if _, ok := store.Get("a"); ok {
+		t.Fatalf("expected the oldest entry to be evicted once over quota")
+	}
+	if _, ok := store.Get("b"); !ok {
+		t.Fatalf("expected the newest entry to remain")
+	}
+}
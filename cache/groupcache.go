@@ -0,0 +1,398 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// PeerPicker owns the consistent-hash ring used to decide which peer in the
+// fleet owns a given key. Implementations must be safe for concurrent use.
+type PeerPicker interface {
+	// PickPeer returns the address that owns key, and whether that address
+	// is a remote peer (false means the local instance owns it).
+	PickPeer(key string) (peer string, isRemote bool)
+	// SetPeers replaces the current peer set, e.g. after a membership change.
+	SetPeers(peers []string)
+}
+
+// defaultReplicas is the number of virtual nodes placed on the ring per peer,
+// smoothing out key distribution across a small peer set.
+const defaultReplicas = 50
+
+// hashRing is the default PeerPicker: a consistent-hash ring over CRC32 of
+// "<replica>peer", following the same scheme as groupcache's consistenthash.
+type hashRing struct {
+	mutex      sync.RWMutex
+	self       string
+	replicas   int
+	sortedKeys []uint32
+	keyToPeer  map[uint32]string
+}
+
+func newHashRing(self string, replicas int) *hashRing {
+	return &hashRing{
+		self:     self,
+		replicas: replicas,
+	}
+}
+
+func (r *hashRing) SetPeers(peers []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sortedKeys = make([]uint32, 0, len(peers)*r.replicas)
+	r.keyToPeer = make(map[uint32]string, len(peers)*r.replicas)
+	for _, peer := range peers {
+		for replica := 0; replica < r.replicas; replica++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(replica) + peer))
+			r.sortedKeys = append(r.sortedKeys, hash)
+			r.keyToPeer[hash] = peer
+		}
+	}
+	sort.Slice(r.sortedKeys, func(i, j int) bool { return r.sortedKeys[i] < r.sortedKeys[j] })
+}
+
+func (r *hashRing) PickPeer(key string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.sortedKeys) == 0 {
+		return r.self, false
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.sortedKeys), func(i int) bool { return r.sortedKeys[i] >= hash })
+	if idx == len(r.sortedKeys) {
+		idx = 0
+	}
+	peer := r.keyToPeer[r.sortedKeys[idx]]
+	return peer, peer != r.self
+}
+
+// GroupCacheStore shards ResponseCacheEntry storage across a peer set via
+// consistent hashing, so a fleet of instances behaves as one big cache
+// without a central Redis. Keys owned by this instance are served from an
+// InMemoryQuotaLRU; keys owned by another peer are fetched/pushed over HTTP,
+// with singleflight collapsing concurrent fills for the same key.
+type GroupCacheStore struct {
+	self   string
+	local  Store
+	picker PeerPicker
+	client *http.Client
+	flight singleflight.Group
+
+	mutex sync.RWMutex
+	peers []string // all known peer addresses, including self
+}
+
+// NewGroupCacheStore creates a GroupCacheStore. self is this instance's own
+// peer address (e.g. "http://10.0.0.1:8080") as it should be advertised to
+// the rest of the fleet; peers is the initial remote peer set. maxBytes
+// bounds the local shard's memory the same way InMemoryQuotaLRU does.
+func NewGroupCacheStore(self string, peers []string, maxBytes int64) *GroupCacheStore {
+	ring := newHashRing(self, defaultReplicas)
+	all := append([]string{self}, peers...)
+	ring.SetPeers(all)
+
+	return &GroupCacheStore{
+		self:   self,
+		local:  newInMemoryQuotaLRUBytes(maxBytes),
+		picker: ring,
+		client: &http.Client{},
+		peers:  all,
+	}
+}
+
+// SetPeers updates the peer set backing the consistent-hash ring, e.g. in
+// response to a service-discovery change.
+func (g *GroupCacheStore) SetPeers(peers []string) {
+	all := append([]string{g.self}, peers...)
+	g.picker.SetPeers(all)
+
+	g.mutex.Lock()
+	g.peers = all
+	g.mutex.Unlock()
+}
+
+// PeerHandler returns the HTTP handler this instance must expose so other
+// peers can read/write the keys it owns.
+func (g *GroupCacheStore) PeerHandler() http.Handler {
+	return GroupCachePeerHandler(g.local)
+}
+
+func (g *GroupCacheStore) Get(key string) (*ResponseCacheEntry, bool) {
+	peer, remote := g.picker.PickPeer(key)
+	if !remote {
+		return g.local.Get(key)
+	}
+
+	value, err, _ := g.flight.Do("get:"+key, func() (interface{}, error) {
+		return g.fetchFromPeer(peer, key)
+	})
+	if err != nil || value == nil {
+		return nil, false
+	}
+	return value.(*ResponseCacheEntry), true
+}
+
+func (g *GroupCacheStore) Set(key string, entry *ResponseCacheEntry) error {
+	peer, remote := g.picker.PickPeer(key)
+	if !remote {
+		return g.local.Set(key, entry)
+	}
+
+	// Writes are never deduplicated through g.flight: two concurrent Sets for
+	// the same key carry different entries, and singleflight would collapse
+	// them into one RPC, silently dropping whichever one didn't execute.
+	return g.sendToPeer(peer, http.MethodPut, key, entry)
+}
+
+func (g *GroupCacheStore) Delete(key string) error {
+	peer, remote := g.picker.PickPeer(key)
+	if !remote {
+		return g.local.Delete(key)
+	}
+
+	return g.sendToPeer(peer, http.MethodDelete, key, nil)
+}
+
+// OpenReader returns key's metadata and a reader over its body. Remote keys
+// are still fetched in full over HTTP first (peer transport isn't
+// streaming), then served from an in-memory reader.
+func (g *GroupCacheStore) OpenReader(key string) (ResponseCacheEntry, BlobReader, bool) {
+	peer, remote := g.picker.PickPeer(key)
+	if !remote {
+		return g.local.OpenReader(key)
+	}
+
+	value, err, _ := g.flight.Do("get:"+key, func() (interface{}, error) {
+		return g.fetchFromPeer(peer, key)
+	})
+	if err != nil || value == nil {
+		return ResponseCacheEntry{}, nil, false
+	}
+	entry := value.(*ResponseCacheEntry)
+	meta := *entry
+	meta.Body = nil
+	return meta, io.NopCloser(bytes.NewReader(entry.Body)), true
+}
+
+// BeginWrite returns a BlobWriter for key. For a locally-owned key it spools
+// straight into the local shard; for a remote key it buffers in memory (the
+// peer transport isn't streaming yet) and pushes the full entry on Commit.
+func (g *GroupCacheStore) BeginWrite(key string, meta ResponseCacheEntry) (BlobWriter, error) {
+	peer, remote := g.picker.PickPeer(key)
+	if !remote {
+		return g.local.BeginWrite(key, meta)
+	}
+	return &remotePeerBlobWriter{store: g, peer: peer, key: key, meta: meta}, nil
+}
+
+// remotePeerBlobWriter buffers a response body in memory, then pushes the
+// full ResponseCacheEntry to the owning peer on Commit.
+type remotePeerBlobWriter struct {
+	store *GroupCacheStore
+	peer  string
+	key   string
+	meta  ResponseCacheEntry
+	buf   bytes.Buffer
+}
+
+func (w *remotePeerBlobWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *remotePeerBlobWriter) Commit() error {
+	entry := w.meta
+	entry.Body = w.buf.Bytes()
+	return w.store.sendToPeer(w.peer, http.MethodPut, w.key, &entry)
+}
+
+func (w *remotePeerBlobWriter) Abort() error {
+	w.buf.Reset()
+	return nil
+}
+
+// DeleteByTag removes tag's entries from every peer in the fleet, since the
+// entries carrying a tag may be sharded across any of them.
+func (g *GroupCacheStore) DeleteByTag(tag string) error {
+	return g.broadcast(func(store Store) error {
+		return store.DeleteByTag(tag)
+	}, "tag", tag)
+}
+
+// Purge removes prefix-matching entries from every peer in the fleet.
+func (g *GroupCacheStore) Purge(prefix string) error {
+	return g.broadcast(func(store Store) error {
+		return store.Purge(prefix)
+	}, "prefix", prefix)
+}
+
+// broadcast applies localOp to this instance's local shard, and forwards the
+// same operation (identified by queryParam=value) to every remote peer.
+// It returns the first error encountered, after attempting every peer.
+func (g *GroupCacheStore) broadcast(localOp func(Store) error, queryParam, value string) error {
+	g.mutex.RLock()
+	peers := append([]string(nil), g.peers...)
+	g.mutex.RUnlock()
+
+	var firstErr error
+	for _, peer := range peers {
+		var err error
+		if peer == g.self {
+			err = localOp(g.local)
+		} else {
+			err = g.sendGroupOp(peer, queryParam, value)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (g *GroupCacheStore) sendGroupOp(peer, queryParam, value string) error {
+	req, err := http.NewRequest(http.MethodDelete, peer+"/_groupcache/get?"+queryParam+"="+url.QueryEscape(value), nil)
+	if err != nil {
+		return fmt.Errorf("groupcache: building %s request for peer %s: %w", queryParam, peer, err)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("groupcache: %s=%s to peer %s: %w", queryParam, value, peer, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("groupcache: peer %s returned %d for %s=%s", peer, resp.StatusCode, queryParam, value)
+	}
+	return nil
+}
+
+func (g *GroupCacheStore) Close() error {
+	return g.local.Close()
+}
+
+func (g *GroupCacheStore) fetchFromPeer(peer, key string) (*ResponseCacheEntry, error) {
+	resp, err := g.client.Get(peer + "/_groupcache/get?key=" + url.QueryEscape(key))
+	if err != nil {
+		return nil, fmt.Errorf("groupcache: fetching %q from peer %s: %w", key, peer, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("groupcache: peer %s returned %d for %q", peer, resp.StatusCode, key)
+	}
+
+	var entry ResponseCacheEntry
+	if err := gob.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("groupcache: decoding entry for %q from peer %s: %w", key, peer, err)
+	}
+	return &entry, nil
+}
+
+func (g *GroupCacheStore) sendToPeer(peer, method, key string, entry *ResponseCacheEntry) error {
+	var body io.Reader
+	if entry != nil {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+			return fmt.Errorf("groupcache: encoding entry for %q: %w", key, err)
+		}
+		body = &buf
+	}
+
+	req, err := http.NewRequest(method, peer+"/_groupcache/get?key="+url.QueryEscape(key), body)
+	if err != nil {
+		return fmt.Errorf("groupcache: building %s request for %q to peer %s: %w", method, key, peer, err)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("groupcache: %s %q to peer %s: %w", method, key, peer, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("groupcache: peer %s returned %d for %s %q", peer, resp.StatusCode, method, key)
+	}
+	return nil
+}
+
+// GroupCachePeerHandler services peer-to-peer requests for the ResponseCacheEntry
+// values owned by local: GET reads a key, PUT stores it (gob-encoded body),
+// and DELETE removes it. Mount it at a well-known path (e.g. "/_groupcache/get")
+// on every instance in the fleet.
+func GroupCachePeerHandler(local Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			if tag := r.URL.Query().Get("tag"); tag != "" {
+				if err := local.DeleteByTag(tag); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+				if err := local.Purge(prefix); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "missing key, tag, or prefix", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			entry, ok := local.Get(key)
+			if !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if err := gob.NewEncoder(w).Encode(entry); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case http.MethodPut:
+			var entry ResponseCacheEntry
+			if err := gob.NewDecoder(r.Body).Decode(&entry); err != nil {
+				http.Error(w, "bad payload: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := local.Set(key, &entry); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			if err := local.Delete(key); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
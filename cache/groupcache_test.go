@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestGroupCacheStoreSingleNodeIsLocal(t *testing.T) {
+	store := NewGroupCacheStore("self", nil, 1024*1024)
+
+	entry := &ResponseCacheEntry{StatusCode: 200, Body: []byte("hello")}
+	if err := store.Set("k", entry); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, ok := store.Get("k")
+	if !ok {
+		t.Fatalf("expected hit after Set")
+	}
+	if string(got.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", got.Body)
+	}
+
+	if err := store.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := store.Get("k"); ok {
+		t.Fatalf("expected miss after Delete")
+	}
+}
+
+func TestGroupCacheStoreSetIsNotDeduplicatedAcrossConcurrentWrites(t *testing.T) {
+	var putCount int
+	var mu sync.Mutex
+	peerHandler := GroupCachePeerHandler(newInMemoryQuotaLRUBytes(1024 * 1024))
+	server := httptest.NewServer(countingPutHandler(peerHandler, &mu, &putCount))
+	defer server.Close()
+
+	localStore := NewGroupCacheStore("http://local", []string{server.URL}, 1024*1024)
+
+	// Find a key this instance doesn't own, so Set actually goes over HTTP.
+	var key string
+	for i := 0; ; i++ {
+		candidate := fmt.Sprintf("key-%d", i)
+		if _, remote := localStore.picker.PickPeer(candidate); remote {
+			key = candidate
+			break
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, body := range []string{"v1", "v2"} {
+		body := body
+		go func() {
+			defer wg.Done()
+			if err := localStore.Set(key, &ResponseCacheEntry{StatusCode: 200, Body: []byte(body)}); err != nil {
+				t.Errorf("Set(%q): %v", body, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if putCount != 2 {
+		t.Fatalf("expected both concurrent Sets to reach the peer, got %d PUTs", putCount)
+	}
+}
+
+func countingPutHandler(next http.Handler, mu *sync.Mutex, count *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mu.Lock()
+			*count++
+			mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestHashRingPicksSelfWithNoPeers(t *testing.T) {
+	ring := newHashRing("self", defaultReplicas)
+	ring.SetPeers([]string{"self"})
+
+	peer, remote := ring.PickPeer("any-key")
+	if remote {
+		t.Fatalf("expected local ownership, got remote peer %q", peer)
+	}
+	if peer != "self" {
+		t.Fatalf("expected self, got %q", peer)
+	}
+}
@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"bytes"
 	"container/list"
+	"io"
+	"strings"
 	"sync"
 )
 
@@ -22,15 +25,38 @@ type InMemoryQuotaLRU struct {
 	maxBytes int64
 	// Current total size of all stored items
 	currentBytes int64
+	// tagIndex maps a tag to the set of keys currently carrying it, so
+	// DeleteByTag doesn't need to scan the whole cache.
+	tagIndex map[string]map[string]struct{}
+	// metrics, if non-nil, is notified of quota-driven LRU evictions. It's
+	// distinct from the generic NewObservableStore decorator because this
+	// eviction happens internally inside Set, where a wrapping decorator
+	// can't observe it.
+	metrics MetricsRecorder
 }
 
 // NewInMemoryQuotaLRU creates a new InMemoryQuotaLRU cache.
 // maxMB is the memory limit in megabytes.
 func NewInMemoryQuotaLRU(maxMB int) Store {
+	return newInMemoryQuotaLRUBytes(int64(maxMB) * 1024 * 1024)
+}
+
+// NewInMemoryQuotaLRUWithMetrics is like NewInMemoryQuotaLRU but reports
+// every quota-driven eviction to metrics via OnEvict(key, bytes, "quota").
+func NewInMemoryQuotaLRUWithMetrics(maxMB int, metrics MetricsRecorder) Store {
+	lru := newInMemoryQuotaLRUBytes(int64(maxMB) * 1024 * 1024).(*InMemoryQuotaLRU)
+	lru.metrics = metrics
+	return lru
+}
+
+// newInMemoryQuotaLRUBytes is like NewInMemoryQuotaLRU but takes the limit in
+// bytes, for callers (e.g. GroupCacheStore) that already size shards in bytes.
+func newInMemoryQuotaLRUBytes(maxBytes int64) Store {
 	return &InMemoryQuotaLRU{
 		lru:      list.New(),
 		cache:    make(map[string]*list.Element),
-		maxBytes: int64(maxMB) * 1024 * 1024,
+		maxBytes: maxBytes,
+		tagIndex: make(map[string]map[string]struct{}),
 	}
 }
 
@@ -63,15 +89,18 @@ func (lru *InMemoryQuotaLRU) Set(key string, entry *ResponseCacheEntry) error {
 	if element, ok := lru.cache[key]; ok {
 		oldEntry := element.Value.(*lruEntry)
 		lru.currentBytes -= oldEntry.size
+		lru.removeFromTagIndex(key, oldEntry.value.Tags)
 		oldEntry.size = itemSize
 		oldEntry.value = entry
 		lru.currentBytes += itemSize
+		lru.addToTagIndex(key, entry.Tags)
 		lru.lru.MoveToFront(element)
 	} else {
 		newEntry := &lruEntry{key: key, size: itemSize, value: entry}
 		element := lru.lru.PushFront(newEntry)
 		lru.cache[key] = element
 		lru.currentBytes += itemSize
+		lru.addToTagIndex(key, entry.Tags)
 	}
 
 	// Eviction
@@ -83,6 +112,10 @@ func (lru *InMemoryQuotaLRU) Set(key string, entry *ResponseCacheEntry) error {
 		evictedEntry := lru.lru.Remove(lruElement).(*lruEntry)
 		delete(lru.cache, evictedEntry.key)
 		lru.currentBytes -= evictedEntry.size
+		lru.removeFromTagIndex(evictedEntry.key, evictedEntry.value.Tags)
+		if lru.metrics != nil {
+			lru.metrics.OnEvict(evictedEntry.key, evictedEntry.size, "quota")
+		}
 	}
 	return nil
 }
@@ -96,10 +129,120 @@ func (lru *InMemoryQuotaLRU) Delete(key string) error {
 		evictedEntry := lru.lru.Remove(element).(*lruEntry)
 		delete(lru.cache, evictedEntry.key)
 		lru.currentBytes -= evictedEntry.size
+		lru.removeFromTagIndex(evictedEntry.key, evictedEntry.value.Tags)
+	}
+	return nil
+}
+
+// DeleteByTag removes every entry currently carrying tag, using the
+// tag->keys index so the cost is proportional to the group size, not the
+// whole cache.
+func (lru *InMemoryQuotaLRU) DeleteByTag(tag string) error {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	keys, ok := lru.tagIndex[tag]
+	if !ok {
+		return nil
+	}
+	for key := range keys {
+		element, ok := lru.cache[key]
+		if !ok {
+			continue
+		}
+		evictedEntry := lru.lru.Remove(element).(*lruEntry)
+		delete(lru.cache, key)
+		lru.currentBytes -= evictedEntry.size
+		lru.removeFromTagIndex(key, evictedEntry.value.Tags)
 	}
 	return nil
 }
 
+// Purge removes every entry whose key starts with prefix.
+func (lru *InMemoryQuotaLRU) Purge(prefix string) error {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	for key, element := range lru.cache {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		evictedEntry := lru.lru.Remove(element).(*lruEntry)
+		delete(lru.cache, key)
+		lru.currentBytes -= evictedEntry.size
+		lru.removeFromTagIndex(key, evictedEntry.value.Tags)
+	}
+	return nil
+}
+
+// addToTagIndex records that key carries tags. Callers must hold lru.mutex.
+func (lru *InMemoryQuotaLRU) addToTagIndex(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := lru.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			lru.tagIndex[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// removeFromTagIndex drops key from tags' index entries. Callers must hold lru.mutex.
+func (lru *InMemoryQuotaLRU) removeFromTagIndex(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := lru.tagIndex[tag]
+		if !ok {
+			continue
+		}
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(lru.tagIndex, tag)
+		}
+	}
+}
+
+// OpenReader returns entry's metadata (Body left nil; read it via the
+// returned BlobReader instead) and a reader over its body bytes.
+func (lru *InMemoryQuotaLRU) OpenReader(key string) (ResponseCacheEntry, BlobReader, bool) {
+	entry, ok := lru.Get(key)
+	if !ok {
+		return ResponseCacheEntry{}, nil, false
+	}
+	meta := *entry
+	meta.Body = nil
+	return meta, io.NopCloser(bytes.NewReader(entry.Body)), true
+}
+
+// BeginWrite returns a BlobWriter that buffers into memory and, on Commit,
+// stores meta (with the buffered bytes as Body) the same way Set does.
+func (lru *InMemoryQuotaLRU) BeginWrite(key string, meta ResponseCacheEntry) (BlobWriter, error) {
+	return &inMemoryBlobWriter{lru: lru, key: key, meta: meta}, nil
+}
+
+// inMemoryBlobWriter buffers a response body in memory until Commit, at
+// which point it's stored exactly as Set would store it.
+type inMemoryBlobWriter struct {
+	lru  *InMemoryQuotaLRU
+	key  string
+	meta ResponseCacheEntry
+	buf  bytes.Buffer
+}
+
+func (w *inMemoryBlobWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *inMemoryBlobWriter) Commit() error {
+	entry := w.meta
+	entry.Body = w.buf.Bytes()
+	return w.lru.Set(w.key, &entry)
+}
+
+func (w *inMemoryBlobWriter) Abort() error {
+	w.buf.Reset()
+	return nil
+}
+
 // Close is a no-op for in-memory, but required by the interface.
 func (lru *InMemoryQuotaLRU) Close() error {
 	return nil
@@ -0,0 +1,44 @@
+package cache
+
+import "testing"
+
+func TestInMemoryQuotaLRUDeleteByTag(t *testing.T) {
+	lru := NewInMemoryQuotaLRU(1)
+
+	lru.Set("user:1", &ResponseCacheEntry{Body: []byte("a"), Tags: []string{"user:1", "tenant:7"}})
+	lru.Set("user:2", &ResponseCacheEntry{Body: []byte("b"), Tags: []string{"user:2", "tenant:7"}})
+	lru.Set("post:1", &ResponseCacheEntry{Body: []byte("c"), Tags: []string{"post:1"}})
+
+	if err := lru.DeleteByTag("tenant:7"); err != nil {
+		t.Fatalf("DeleteByTag: %v", err)
+	}
+
+	if _, ok := lru.Get("user:1"); ok {
+		t.Fatalf("expected user:1 to be purged")
+	}
+	if _, ok := lru.Get("user:2"); ok {
+		t.Fatalf("expected user:2 to be purged")
+	}
+	if _, ok := lru.Get("post:1"); !ok {
+		t.Fatalf("expected post:1 to survive an unrelated tag purge")
+	}
+}
+
+func TestInMemoryQuotaLRUPurgeByPrefix(t *testing.T) {
+	lru := NewInMemoryQuotaLRU(1)
+
+	lru.Set("cache:GET:/users/1", &ResponseCacheEntry{Body: []byte("a")})
+	lru.Set("cache:GET:/users/2", &ResponseCacheEntry{Body: []byte("b")})
+	lru.Set("cache:GET:/posts/1", &ResponseCacheEntry{Body: []byte("c")})
+
+	if err := lru.Purge("cache:GET:/users/"); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	if _, ok := lru.Get("cache:GET:/users/1"); ok {
+		t.Fatalf("expected /users/1 to be purged")
+	}
+	if _, ok := lru.Get("cache:GET:/posts/1"); !ok {
+		t.Fatalf("expected /posts/1 to survive an unrelated purge")
+	}
+}
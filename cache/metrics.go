@@ -0,0 +1,132 @@
+package cache
+
+import "time"
+
+// MetricsRecorder receives cache lifecycle events so an operator can wire
+// capsulecache into whatever observability stack they run (Prometheus,
+// StatsD, structured logs, ...). capsulecache/metrics/prom ships a
+// Prometheus-backed implementation; callers can also implement the
+// interface directly.
+type MetricsRecorder interface {
+	// OnHit is called when a cached entry is served. stale reports whether
+	// it was served past its freshness lifetime (e.g. during SWR).
+	OnHit(key string, stale bool)
+	// OnMiss is called when no usable cached entry was found for key.
+	OnMiss(key string)
+	// OnStore is called after a response has been written to the store.
+	OnStore(key string, bytes int64)
+	// OnEvict is called when an entry is removed before a new lookup would
+	// have found it stale on its own - e.g. "quota" (LRU pressure),
+	// "manual" (Delete), "tag", or "prefix" (DeleteByTag/Purge).
+	OnEvict(key string, bytes int64, reason string)
+	// OnRevalidate is called after an upstream revalidation request
+	// completes (whether it answered 304 or replaced the entry).
+	OnRevalidate(key string, status int, dur time.Duration)
+}
+
+// NewObservableStore wraps inner so every Get/Set/Delete/DeleteByTag/Purge
+// reports to metrics, regardless of which Store implementation is used.
+// Stores that perform their own internal eviction (e.g. InMemoryQuotaLRU's
+// quota-driven LRU eviction) should be given a MetricsRecorder directly
+// instead, since the decorator can't see removals it didn't initiate;
+// NewObservableStore still reports OnEvict for the removals it does
+// initiate (Delete, DeleteByTag, Purge).
+func NewObservableStore(inner Store, metrics MetricsRecorder) Store {
+	return &observableStore{inner: inner, metrics: metrics}
+}
+
+type observableStore struct {
+	inner   Store
+	metrics MetricsRecorder
+}
+
+func (o *observableStore) Get(key string) (*ResponseCacheEntry, bool) {
+	entry, ok := o.inner.Get(key)
+	if ok {
+		o.metrics.OnHit(key, entry.IsStale())
+	} else {
+		o.metrics.OnMiss(key)
+	}
+	return entry, ok
+}
+
+func (o *observableStore) Set(key string, entry *ResponseCacheEntry) error {
+	if err := o.inner.Set(key, entry); err != nil {
+		return err
+	}
+	o.metrics.OnStore(key, entry.Size())
+	return nil
+}
+
+func (o *observableStore) Delete(key string) error {
+	if err := o.inner.Delete(key); err != nil {
+		return err
+	}
+	o.metrics.OnEvict(key, 0, "manual")
+	return nil
+}
+
+func (o *observableStore) DeleteByTag(tag string) error {
+	if err := o.inner.DeleteByTag(tag); err != nil {
+		return err
+	}
+	o.metrics.OnEvict(tag, 0, "tag")
+	return nil
+}
+
+func (o *observableStore) Purge(prefix string) error {
+	if err := o.inner.Purge(prefix); err != nil {
+		return err
+	}
+	o.metrics.OnEvict(prefix, 0, "prefix")
+	return nil
+}
+
+func (o *observableStore) OpenReader(key string) (ResponseCacheEntry, BlobReader, bool) {
+	meta, reader, ok := o.inner.OpenReader(key)
+	if ok {
+		o.metrics.OnHit(key, meta.IsStale())
+	} else {
+		o.metrics.OnMiss(key)
+	}
+	return meta, reader, ok
+}
+
+func (o *observableStore) BeginWrite(key string, meta ResponseCacheEntry) (BlobWriter, error) {
+	writer, err := o.inner.BeginWrite(key, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &observableBlobWriter{inner: writer, key: key, metrics: o.metrics}, nil
+}
+
+func (o *observableStore) Close() error {
+	return o.inner.Close()
+}
+
+// observableBlobWriter tallies the bytes written so Commit can report an
+// accurate OnStore, without requiring the whole body to be buffered.
+type observableBlobWriter struct {
+	inner   BlobWriter
+	key     string
+	metrics MetricsRecorder
+	bytes   int64
+}
+
+func (w *observableBlobWriter) Write(p []byte) (int, error) {
+	n, err := w.inner.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *observableBlobWriter) Commit() error {
+	if err := w.inner.Commit(); err != nil {
+		return err
+	}
+	w.metrics.OnStore(w.key, w.bytes)
+	return nil
+}
+
+func (w *observableBlobWriter) Abort() error {
+	return w.inner.Abort()
+}
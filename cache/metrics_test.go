@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	hits, misses   []string
+	stores, evicts []string
+	revalidations  int
+}
+
+func (m *recordingMetrics) OnHit(key string, stale bool) { m.hits = append(m.hits, key) }
+func (m *recordingMetrics) OnMiss(key string)            { m.misses = append(m.misses, key) }
+func (m *recordingMetrics) OnStore(key string, bytes int64) {
+	m.stores = append(m.stores, key)
+}
+func (m *recordingMetrics) OnEvict(key string, bytes int64, reason string) {
+	m.evicts = append(m.evicts, reason)
+}
+func (m *recordingMetrics) OnRevalidate(key string, status int, dur time.Duration) {
+	m.revalidations++
+}
+
+func TestObservableStoreReportsHitsAndMisses(t *testing.T) {
+	metrics := &recordingMetrics{}
+	store := NewObservableStore(NewInMemoryQuotaLRU(1), metrics)
+
+	if _, ok := store.Get("missing"); ok {
+		t.Fatalf("expected miss")
+	}
+
+	if err := store.Set("present", &ResponseCacheEntry{Body: []byte("a")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, ok := store.Get("present"); !ok {
+		t.Fatalf("expected hit")
+	}
+
+	if len(metrics.misses) != 1 || metrics.misses[0] != "missing" {
+		t.Fatalf("expected one miss for %q, got %v", "missing", metrics.misses)
+	}
+	if len(metrics.hits) != 1 || metrics.hits[0] != "present" {
+		t.Fatalf("expected one hit for %q, got %v", "present", metrics.hits)
+	}
+	if len(metrics.stores) != 1 {
+		t.Fatalf("expected one store, got %v", metrics.stores)
+	}
+}
+
+func TestObservableStoreReportsDeleteAndOpenReader(t *testing.T) {
+	metrics := &recordingMetrics{}
+	store := NewObservableStore(NewInMemoryQuotaLRU(1), metrics)
+
+	_ = store.Set("key", &ResponseCacheEntry{Body: []byte("a")})
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(metrics.evicts) != 1 || metrics.evicts[0] != "manual" {
+		t.Fatalf("expected one manual eviction, got %v", metrics.evicts)
+	}
+
+	if _, _, ok := store.OpenReader("key"); ok {
+		t.Fatalf("expected miss after delete")
+	}
+	if len(metrics.misses) != 1 {
+		t.Fatalf("expected a miss recorded for OpenReader, got %v", metrics.misses)
+	}
+}
+
+func TestObservableStoreReportsBeginWriteCommit(t *testing.T) {
+	metrics := &recordingMetrics{}
+	store := NewObservableStore(NewInMemoryQuotaLRU(1), metrics)
+
+	writer, err := store.BeginWrite("key", ResponseCacheEntry{})
+	if err != nil {
+		t.Fatalf("BeginWrite: %v", err)
+	}
+	if _, err := writer.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if len(metrics.stores) != 1 || metrics.stores[0] != "key" {
+		t.Fatalf("expected one store for %q, got %v", "key", metrics.stores)
+	}
+
+	_, reader, ok := store.OpenReader("key")
+	if !ok {
+		t.Fatalf("expected committed entry to be readable")
+	}
+	body, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	if string(body) != "hello" {
+		t.Fatalf("expected body %q, got %q", "hello", body)
+	}
+}
+
+func TestInMemoryQuotaLRUWithMetricsReportsQuotaEvictions(t *testing.T) {
+	metrics := &recordingMetrics{}
+	store := NewInMemoryQuotaLRUWithMetrics(0, metrics)
+
+	if err := store.Set("a", &ResponseCacheEntry{Body: []byte("aaaaa")}); err != nil {
+		t.Fatalf("Set a: %v", err)
+	}
+	if err := store.Set("b", &ResponseCacheEntry{Body: []byte("bbbbbb")}); err != nil {
+		t.Fatalf("Set b: %v", err)
+	}
+
+	if len(metrics.evicts) != 2 || metrics.evicts[0] != "quota" {
+		t.Fatalf("expected quota evictions for both entries, got %v", metrics.evicts)
+	}
+}
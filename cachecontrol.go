@@ -0,0 +1,346 @@
+package capsulecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spdeepak/capsulecache/cache"
+)
+
+// buildRFC7234Entry builds the cache entry for a freshly fetched response,
+// deriving TTL/SWR/stale-if-error and Vary bookkeeping from its Cache-Control
+// (and Expires/Date/Last-Modified) headers. ok is false when the response
+// must not be stored at all (e.g. Cache-Control: no-store).
+func buildRFC7234Entry(header http.Header, statusCode int, body []byte, request *http.Request, now time.Time, defaultSWR time.Duration, tags []string) (entry *cache.ResponseCacheEntry, ok bool) {
+	respDirectives := parseCacheControl(header.Get("Cache-Control"))
+	if respDirectives.Private && request != nil {
+		// Private responses are not meant for a shared cache; capsulecache
+		// always caches per-instance, so treat it the same as no-store here
+		// rather than silently serving it to other clients.
+		return nil, false
+	}
+
+	ttl, cacheable := freshnessLifetime(respDirectives, header, now)
+	if !cacheable {
+		return nil, false
+	}
+	// no-cache means "store it, but always revalidate before reuse" -
+	// modeled as an entry that is immediately stale.
+	if respDirectives.NoCache {
+		ttl = 0
+	}
+
+	swr := defaultSWR
+	if respDirectives.StaleWhileRevalidate != nil {
+		swr = time.Duration(*respDirectives.StaleWhileRevalidate) * time.Second
+	}
+	if respDirectives.MustRevalidate {
+		swr = 0
+	}
+
+	var staleIfError time.Duration
+	if respDirectives.StaleIfError != nil {
+		staleIfError = time.Duration(*respDirectives.StaleIfError) * time.Second
+	}
+
+	// no-cache requires revalidation before every reuse, so the entry must
+	// never be served unvalidated - neither via the SWR window (which would
+	// serve the stale body while refreshing in the background) nor via the
+	// stale-if-error fallback.
+	if respDirectives.NoCache {
+		swr = 0
+		staleIfError = 0
+	}
+
+	// Vary: * means no later request can be known to match this response,
+	// so it is never stored.
+	for _, name := range strings.Split(header.Get("Vary"), ",") {
+		if strings.TrimSpace(name) == "*" {
+			return nil, false
+		}
+	}
+	varyNames := varyHeaderNames(header.Get("Vary"))
+	var varyValues map[string]string
+	if request != nil {
+		varyValues = captureVaryValues(varyNames, request.Header)
+	}
+
+	// Backdate CreatedAt by any Age the response already carries (e.g. from
+	// an upstream CDN), so IsStale/IsRotten's time.Since(CreatedAt) reflects
+	// the response's actual current age per RFC 7234 §4.2.3 instead of
+	// restarting its freshness lifetime from zero at our cache.
+	createdAt := now.Add(-currentAge(header, now, now))
+
+	return &cache.ResponseCacheEntry{
+		StatusCode:   statusCode,
+		Headers:      header,
+		Body:         body,
+		CreatedAt:    createdAt,
+		TTL:          ttl,
+		SWR:          swr,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		StaleIfError: staleIfError,
+		Vary:         varyNames,
+		VaryHeaders:  varyValues,
+		Tags:         tags,
+	}, true
+}
+
+// cacheControlDirectives is a parsed view of a Cache-Control header, combined
+// across request or response as appropriate. Unset numeric directives are nil
+// so callers can distinguish "absent" from "zero".
+type cacheControlDirectives struct {
+	NoStore              bool
+	NoCache              bool
+	Private              bool
+	MustRevalidate       bool
+	MaxAge               *int
+	SMaxAge              *int
+	StaleWhileRevalidate *int
+	StaleIfError         *int
+}
+
+// parseCacheControl parses a Cache-Control header value per RFC 7234 §5.2.
+// Unknown directives are ignored.
+func parseCacheControl(header string) cacheControlDirectives {
+	var directives cacheControlDirectives
+	if header == "" {
+		return directives
+	}
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch name {
+		case "no-store":
+			directives.NoStore = true
+		case "no-cache":
+			directives.NoCache = true
+		case "private":
+			directives.Private = true
+		case "must-revalidate", "proxy-revalidate":
+			directives.MustRevalidate = true
+		case "max-age":
+			directives.MaxAge = parseSeconds(value)
+		case "s-maxage":
+			directives.SMaxAge = parseSeconds(value)
+		case "stale-while-revalidate":
+			directives.StaleWhileRevalidate = parseSeconds(value)
+		case "stale-if-error":
+			directives.StaleIfError = parseSeconds(value)
+		}
+	}
+	return directives
+}
+
+// parseSeconds converts a Cache-Control delta-seconds value into a *int,
+// returning nil if it cannot be parsed.
+func parseSeconds(value string) *int {
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	return &seconds
+}
+
+// requestBypassesCache reports whether the incoming request explicitly asks
+// to skip the cache lookup (Cache-Control: no-cache or the legacy Pragma).
+func requestBypassesCache(request *http.Request) bool {
+	reqDirectives := parseCacheControl(request.Header.Get("Cache-Control"))
+	if reqDirectives.NoCache || reqDirectives.NoStore {
+		return true
+	}
+	return strings.Contains(strings.ToLower(request.Header.Get("Pragma")), "no-cache")
+}
+
+// freshnessLifetime computes the freshness lifetime of a response per
+// RFC 7234 §4.2.1: prefer s-maxage, then max-age, then the Expires/Date
+// delta, falling back to a heuristic of 10% of the Date-Last-Modified delta
+// capped at 24h (§4.2.2). ok is false when the response carries no-store;
+// private is not checked here (buildRFC7234Entry rejects it).
+func freshnessLifetime(respDirectives cacheControlDirectives, header http.Header, now time.Time) (ttl time.Duration, ok bool) {
+	if respDirectives.NoStore {
+		return 0, false
+	}
+
+	if respDirectives.SMaxAge != nil {
+		return time.Duration(*respDirectives.SMaxAge) * time.Second, true
+	}
+	if respDirectives.MaxAge != nil {
+		return time.Duration(*respDirectives.MaxAge) * time.Second, true
+	}
+
+	date := parseHTTPDate(header.Get("Date"))
+	if date.IsZero() {
+		date = now
+	}
+	if expires := parseHTTPDate(header.Get("Expires")); !expires.IsZero() {
+		if expires.Before(date) {
+			return 0, true
+		}
+		return expires.Sub(date), true
+	}
+
+	// Heuristic freshness: min(24h, 10% of Date-Last-Modified).
+	if lastModified := parseHTTPDate(header.Get("Last-Modified")); !lastModified.IsZero() && date.After(lastModified) {
+		heuristic := date.Sub(lastModified) / 10
+		if heuristic > 24*time.Hour {
+			heuristic = 24 * time.Hour
+		}
+		return heuristic, true
+	}
+
+	return 0, true
+}
+
+// parseHTTPDate parses an HTTP-date header value, returning the zero Time on
+// failure (http.ParseTime already tries all three RFC 7231 formats).
+func parseHTTPDate(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// currentAge estimates the response's current age per RFC 7234 §4.2.3,
+// accounting for any Age header already present (e.g. from an upstream CDN).
+func currentAge(header http.Header, createdAt, now time.Time) time.Duration {
+	age := now.Sub(createdAt)
+	if ageHeader := header.Get("Age"); ageHeader != "" {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(ageHeader)); err == nil {
+			age += time.Duration(seconds) * time.Second
+		}
+	}
+	return age
+}
+
+// varyHeaderNames splits a response's Vary header into the individual header
+// names it lists, ignoring the "*" wildcard (which makes the response
+// effectively uncacheable across variants and is handled by the caller).
+func varyHeaderNames(varyHeader string) []string {
+	if varyHeader == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(varyHeader, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "*" {
+			continue
+		}
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	return names
+}
+
+// captureVaryValues snapshots the values of the given header names as seen on
+// request, to be compared against later requests before reusing an entry.
+func captureVaryValues(names []string, header http.Header) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		values[name] = header.Get(name)
+	}
+	return values
+}
+
+// varyMatches reports whether request carries the same Vary-listed header
+// values that were captured when entry was stored.
+func varyMatches(entry *cache.ResponseCacheEntry, request *http.Request) bool {
+	for _, name := range entry.Vary {
+		if request.Header.Get(name) != entry.VaryHeaders[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// requestValidatorsMatch reports whether request carries a conditional
+// validator (If-None-Match, or If-Modified-Since as a fallback) that matches
+// entry's stored ETag/Last-Modified, per RFC 7232 §4.1: when If-None-Match is
+// present it decides alone; If-Modified-Since is only consulted in its
+// absence. A match means the client already holds the current
+// representation and should get 304 Not Modified instead of the full body.
+func requestValidatorsMatch(request *http.Request, entry *cache.ResponseCacheEntry) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatchesAny(ifNoneMatch, entry.ETag)
+	}
+	if ifModifiedSince := request.Header.Get("If-Modified-Since"); ifModifiedSince != "" && entry.LastModified != "" {
+		since := parseHTTPDate(ifModifiedSince)
+		lastModified := parseHTTPDate(entry.LastModified)
+		return !since.IsZero() && !lastModified.IsZero() && !lastModified.After(since)
+	}
+	return false
+}
+
+// etagMatchesAny reports whether etag appears in the comma-separated
+// If-None-Match header value, ignoring any weak-validator "W/" prefix on
+// either side (GET/HEAD only ever need the weak comparison, per RFC 7232
+// §2.3.2), or whether the header is the "*" wildcard.
+func etagMatchesAny(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// revalidationHeaders lists the response headers RFC 7232 §4.1 says a 304
+// response may carry to update the stored representation's metadata.
+var revalidationHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// mergeRevalidationHeaders clones stored and overlays any of
+// revalidationHeaders present on fresh (a 304 response), per RFC 7232 §4.1.
+func mergeRevalidationHeaders(stored http.Header, fresh http.Header) http.Header {
+	merged := stored.Clone()
+	for _, name := range revalidationHeaders {
+		if values := fresh.Values(name); len(values) > 0 {
+			merged.Del(name)
+			for _, value := range values {
+				merged.Add(name, value)
+			}
+		}
+	}
+	return merged
+}
+
+// varyVariantKey derives a secondary storage key for a Vary-dependent variant
+// of baseKey, so multiple representations of the same URL can coexist. The
+// key is hashed from the request's values of the Vary-listed headers, so
+// each distinct combination of values gets its own slot.
+func varyVariantKey(baseKey string, varyNames []string, header http.Header) string {
+	var builder strings.Builder
+	for _, name := range varyNames {
+		builder.WriteString(name)
+		builder.WriteByte('=')
+		builder.WriteString(header.Get(name))
+		builder.WriteByte(';')
+	}
+	hash := sha256.Sum256([]byte(builder.String()))
+	return baseKey + ":vary:" + hex.EncodeToString(hash[:8])
+}
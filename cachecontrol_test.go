@@ -0,0 +1,43 @@
+package capsulecache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildRFC7234EntryAccountsForAgeHeader(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=3600")
+	header.Set("Age", "3600")
+
+	entry, ok := buildRFC7234Entry(header, http.StatusOK, nil, httptest.NewRequest("GET", "/", nil), now, time.Minute, nil)
+	if !ok {
+		t.Fatalf("expected entry to be cacheable")
+	}
+	if !entry.IsStale() {
+		t.Fatalf("expected a response already Age: 3600 behind a max-age=3600 directive to be immediately stale")
+	}
+}
+
+func TestBuildRFC7234EntryNoCacheDisablesSWRAndStaleIfError(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	header.Set("Cache-Control", "no-cache, stale-while-revalidate=60, stale-if-error=60")
+
+	entry, ok := buildRFC7234Entry(header, http.StatusOK, nil, httptest.NewRequest("GET", "/", nil), now, time.Minute, nil)
+	if !ok {
+		t.Fatalf("expected entry to be cacheable")
+	}
+	if entry.SWR != 0 {
+		t.Fatalf("expected no-cache to force SWR to 0, got %s", entry.SWR)
+	}
+	if entry.StaleIfError != 0 {
+		t.Fatalf("expected no-cache to force StaleIfError to 0, got %s", entry.StaleIfError)
+	}
+	if !entry.IsRotten() {
+		t.Fatalf("expected a no-cache entry to be immediately rotten (no unvalidated reuse)")
+	}
+}
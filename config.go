@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/spdeepak/capsulecache/cache"
 )
 
 // Config holds the middleware settings.
@@ -19,10 +21,23 @@ type Config struct {
 	KeyGenerator func(*http.Request) string
 	// ShouldCache decides whether a response with given status code should be cached.
 	ShouldCache func(statusCode int) bool
-	// MaxBodyBytes - do not cache bodies larger than this.
-	MaxBodyBytes int64
 	// StripHeaders removes headers before storing (hop-by-hop etc).
 	StripHeaders func(http.Header) http.Header
+	// RespectCacheControl, when true, derives per-entry freshness/revalidation
+	// behavior from the request/response Cache-Control (and Expires/Age/Vary)
+	// headers per RFC 7234 instead of always using DefaultTTL/DefaultSWR.
+	RespectCacheControl bool
+	// TagExtractor derives invalidation tags (e.g. "user:42", "post:7") for a
+	// freshly cached response, from the request and the response's status
+	// and headers (the body is streamed straight to the store and client as
+	// it's produced, so it isn't available here). Tags are attached to the
+	// stored entry so a later mutation can invalidate the whole group via
+	// Store.DeleteByTag, e.g. through PurgeOnMutation.
+	TagExtractor func(request *http.Request, statusCode int, header http.Header) []string
+	// Metrics, if set, is notified of cache hits/misses/stores/revalidations
+	// as NewCacheMiddleware handles requests. See capsulecache/metrics/prom
+	// for a Prometheus-backed implementation.
+	Metrics cache.MetricsRecorder
 }
 
 // DefaultConfig provides defaults.
@@ -37,8 +52,15 @@ var DefaultConfig = &Config{
 	StripHeaders: stripHopByHop,
 }
 
-// DefaultKeyGenerator creates a simple cache key (Method:Path).
+// DefaultKeyGenerator creates a simple cache key (Method:Path) for GET/HEAD
+// requests, and returns "" for everything else, since a URL+method alone
+// isn't a safe cache key for a request that may carry a distinguishing body
+// (e.g. POST). Generators that do account for the body, like
+// AdvancedKeyGenerator or GraphQLKeyGenerator, may return a key for POST.
 func DefaultKeyGenerator(r *http.Request) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return ""
+	}
 	return "cache:" + r.Method + ":" + r.URL.Path
 }
 
@@ -0,0 +1,571 @@
+// Package graphql provides just enough of the GraphQL query language to
+// support cache-key generation: detecting an operation's type (query,
+// mutation, or subscription) and producing a canonical, whitespace- and
+// ordering-insensitive rendering of it. It is not a general-purpose GraphQL
+// parser - fragment spreads and inline fragments are sorted alongside the
+// other selections but never expanded or merged, and variable definitions
+// are re-emitted in their original order, since doing more would require
+// full type information this package deliberately doesn't have.
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OperationType is the kind of operation a GraphQL document defines.
+type OperationType string
+
+const (
+	OperationQuery        OperationType = "query"
+	OperationMutation     OperationType = "mutation"
+	OperationSubscription OperationType = "subscription"
+)
+
+// Operation is the result of parsing a GraphQL query document.
+type Operation struct {
+	Type OperationType
+	// Canonical is query re-rendered with comments stripped, whitespace
+	// normalized, and every selection set's fields (and every field's
+	// arguments) sorted alphabetically, so two requests that only differ in
+	// formatting or field/argument order produce the same Canonical value.
+	Canonical string
+}
+
+// Parse lexes and parses a GraphQL query document, returning its operation
+// type and canonical form.
+func Parse(query string) (Operation, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return Operation{}, err
+	}
+	p := &parser{tokens: tokens}
+
+	opType := OperationQuery
+	var varDefs string
+	if p.peekKeyword("query", "mutation", "subscription") {
+		opType = OperationType(p.next().text)
+		// Optional operation name.
+		if p.peekKind(tokenName) {
+			p.next()
+		}
+		if p.peekPunct("(") {
+			if varDefs, err = p.parseVariableDefinitions(); err != nil {
+				return Operation{}, err
+			}
+		}
+		if p.peekPunct("@") {
+			if _, err := p.parseDirectives(); err != nil {
+				return Operation{}, err
+			}
+		}
+	}
+
+	set, err := p.parseSelectionSet()
+	if err != nil {
+		return Operation{}, err
+	}
+
+	return Operation{Type: opType, Canonical: varDefs + set.render()}, nil
+}
+
+// IsMutationOrSubscription reports whether op is a mutation or a
+// subscription, the operation types that must never be served from a cache.
+func IsMutationOrSubscription(op Operation) bool {
+	return op.Type == OperationMutation || op.Type == OperationSubscription
+}
+
+// --- tokenizer -------------------------------------------------------------
+
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota
+	tokenPunct
+	tokenIntValue
+	tokenFloatValue
+	tokenStringValue
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize strips comments and insignificant commas, then splits query into
+// GraphQL lexical tokens.
+func tokenize(query string) ([]token, error) {
+	runes := []rune(stripComments(query))
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == ',':
+			i++
+		case r == '"':
+			start := i
+			blockString := strings.HasPrefix(string(runes[i:]), `"""`)
+			delim := `"`
+			if blockString {
+				delim = `"""`
+			}
+			i += len(delim)
+			for i < len(runes) && !strings.HasPrefix(string(runes[i:]), delim) {
+				if runes[i] == '\\' && !blockString {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("graphql: unterminated string starting at %d", start)
+			}
+			i += len(delim)
+			tokens = append(tokens, token{kind: tokenStringValue, text: string(runes[start:i])})
+		case r == '_' || isLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || isLetter(runes[i]) || isDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenName, text: string(runes[start:i])})
+		case isDigit(r) || (r == '-' && i+1 < len(runes) && isDigit(runes[i+1])):
+			start := i
+			isFloat := false
+			i++
+			for i < len(runes) && (isDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' || runes[i] == '+' || runes[i] == '-') {
+				if runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' {
+					isFloat = true
+				}
+				i++
+			}
+			kind := tokenIntValue
+			if isFloat {
+				kind = tokenFloatValue
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[start:i])})
+		case strings.HasPrefix(string(runes[i:]), "..."):
+			tokens = append(tokens, token{kind: tokenPunct, text: "..."})
+			i += 3
+		case strings.ContainsRune("!$():=@[]{|}", r):
+			tokens = append(tokens, token{kind: tokenPunct, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("graphql: unexpected character %q at %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+// stripComments removes "#"-to-end-of-line comments, leaving string literals
+// (including block strings) untouched.
+func stripComments(query string) string {
+	var out strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '"':
+			end := i + 1
+			blockString := strings.HasPrefix(string(runes[i:]), `"""`)
+			delim := `"`
+			if blockString {
+				delim = `"""`
+				end = i + 3
+			}
+			out.WriteString(string(runes[i:end]))
+			i = end
+			for i < len(runes) && !strings.HasPrefix(string(runes[i:]), delim) {
+				if runes[i] == '\\' && !blockString && i+1 < len(runes) {
+					out.WriteRune(runes[i])
+					i++
+				}
+				out.WriteRune(runes[i])
+				i++
+			}
+			if i < len(runes) {
+				out.WriteString(delim)
+				i += len(delim) - 1
+			}
+		case runes[i] == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i-- // re-examine the newline (or end) on the next loop increment
+		default:
+			out.WriteRune(runes[i])
+		}
+	}
+	return out.String()
+}
+
+func isLetter(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }
+func isDigit(r rune) bool  { return r >= '0' && r <= '9' }
+
+// --- parser ------------------------------------------------------------
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) peekKind(kind tokenKind) bool {
+	t := p.peek()
+	return t != nil && t.kind == kind
+}
+
+func (p *parser) peekPunct(text string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokenPunct && t.text == text
+}
+
+func (p *parser) peekKeyword(keywords ...string) bool {
+	t := p.peek()
+	if t == nil || t.kind != tokenName {
+		return false
+	}
+	for _, k := range keywords {
+		if t.text == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expectPunct(text string) error {
+	if !p.peekPunct(text) {
+		return fmt.Errorf("graphql: expected %q at token %d", text, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// selectionSet is a sorted list of selections, rendered in canonical form.
+type selectionSet []selectionNode
+
+type selectionNode struct {
+	sortKey string
+	text    string
+}
+
+func (set selectionSet) render() string {
+	if set == nil {
+		return ""
+	}
+	parts := make([]string, len(set))
+	for i, node := range set {
+		parts[i] = node.text
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// parseSelectionSet parses "{ selection selection ... }", sorting the
+// selections by their canonical rendering so field order never affects the
+// result.
+func (p *parser) parseSelectionSet() (selectionSet, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	var nodes []selectionNode
+	for !p.peekPunct("}") {
+		if p.peek() == nil {
+			return nil, fmt.Errorf("graphql: unterminated selection set")
+		}
+		node, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	p.pos++ // consume "}"
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].sortKey < nodes[j].sortKey })
+	return selectionSet(nodes), nil
+}
+
+// parseSelection parses one Field, FragmentSpread, or InlineFragment.
+func (p *parser) parseSelection() (selectionNode, error) {
+	if p.peekPunct("...") {
+		return p.parseFragment()
+	}
+	return p.parseField()
+}
+
+func (p *parser) parseFragment() (selectionNode, error) {
+	p.pos++ // consume "..."
+
+	if p.peekKeyword("on") || !p.peekKind(tokenName) {
+		var typeCondition string
+		if p.peekKeyword("on") {
+			p.pos++
+			typeCondition = p.next().text
+		}
+		directives, err := p.parseDirectives()
+		if err != nil {
+			return selectionNode{}, err
+		}
+		children, err := p.parseSelectionSet()
+		if err != nil {
+			return selectionNode{}, err
+		}
+		text := "..."
+		if typeCondition != "" {
+			text += "on " + typeCondition
+		}
+		text += directives.render() + children.render()
+		return selectionNode{sortKey: "...1" + typeCondition + "|" + text, text: text}, nil
+	}
+
+	name := p.next().text
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return selectionNode{}, err
+	}
+	text := "..." + name + directives.render()
+	return selectionNode{sortKey: "...0" + name + "|" + text, text: text}, nil
+}
+
+func (p *parser) parseField() (selectionNode, error) {
+	if !p.peekKind(tokenName) {
+		return selectionNode{}, fmt.Errorf("graphql: expected field name at token %d", p.pos)
+	}
+	first := p.next().text
+	alias, name := "", first
+	if p.peekPunct(":") {
+		p.pos++
+		if !p.peekKind(tokenName) {
+			return selectionNode{}, fmt.Errorf("graphql: expected field name after alias at token %d", p.pos)
+		}
+		alias = first
+		name = p.next().text
+	}
+
+	args, err := p.parseArguments()
+	if err != nil {
+		return selectionNode{}, err
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return selectionNode{}, err
+	}
+
+	var children selectionSet
+	if p.peekPunct("{") {
+		children, err = p.parseSelectionSet()
+		if err != nil {
+			return selectionNode{}, err
+		}
+	}
+
+	var text strings.Builder
+	if alias != "" {
+		text.WriteString(alias)
+		text.WriteByte(':')
+	}
+	text.WriteString(name)
+	text.WriteString(args.render())
+	text.WriteString(directives.render())
+	text.WriteString(children.render())
+
+	rendered := text.String()
+	// name+alias alone only distinguishes fields that differ by name or
+	// alias; folding in the full rendered text breaks ties between fields
+	// with the same name/alias that differ only in arguments or child
+	// selections, so duplicate-field source order never leaks into the
+	// canonical form.
+	return selectionNode{sortKey: name + ":" + alias + "|" + rendered, text: rendered}, nil
+}
+
+// argumentList is a sorted list of name:value pairs shared by field
+// arguments and directive arguments.
+type argumentList []argumentNode
+
+type argumentNode struct {
+	name  string
+	value string
+}
+
+func (args argumentList) render() string {
+	if args == nil {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.name + ":" + a.value
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// parseArguments parses an optional "(name: value, ...)" list, sorting
+// entries by argument name.
+func (p *parser) parseArguments() (argumentList, error) {
+	if !p.peekPunct("(") {
+		return nil, nil
+	}
+	p.pos++
+
+	var args []argumentNode
+	for !p.peekPunct(")") {
+		if !p.peekKind(tokenName) {
+			return nil, fmt.Errorf("graphql: expected argument name at token %d", p.pos)
+		}
+		name := p.next().text
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argumentNode{name: name, value: value})
+	}
+	p.pos++ // consume ")"
+
+	sort.Slice(args, func(i, j int) bool { return args[i].name < args[j].name })
+	return argumentList(args), nil
+}
+
+// directiveList is an ordered list of "@name(args)" directives. Unlike
+// arguments, directive order is semantically meaningful (e.g. repeated
+// custom directives), so it's preserved rather than sorted.
+type directiveList []directiveNode
+
+type directiveNode struct {
+	name string
+	args argumentList
+}
+
+func (directives directiveList) render() string {
+	if directives == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, d := range directives {
+		b.WriteByte(' ')
+		b.WriteByte('@')
+		b.WriteString(d.name)
+		b.WriteString(d.args.render())
+	}
+	return b.String()
+}
+
+// parseDirectives parses zero or more "@name(args)" directives, in the
+// order they appear.
+func (p *parser) parseDirectives() (directiveList, error) {
+	var directives []directiveNode
+	for p.peekPunct("@") {
+		p.pos++
+		if !p.peekKind(tokenName) {
+			return nil, fmt.Errorf("graphql: expected directive name at token %d", p.pos)
+		}
+		name := p.next().text
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, directiveNode{name: name, args: args})
+	}
+	return directiveList(directives), nil
+}
+
+// parseValue parses any GraphQL value and returns its canonical rendering:
+// object field names are sorted (objects are semantically unordered maps),
+// everything else is rendered with normalized, minimal punctuation.
+func (p *parser) parseValue() (string, error) {
+	t := p.peek()
+	if t == nil {
+		return "", fmt.Errorf("graphql: expected value at token %d", p.pos)
+	}
+
+	switch {
+	case t.kind == tokenPunct && t.text == "$":
+		p.pos++
+		if !p.peekKind(tokenName) {
+			return "", fmt.Errorf("graphql: expected variable name at token %d", p.pos)
+		}
+		return "$" + p.next().text, nil
+	case t.kind == tokenPunct && t.text == "[":
+		p.pos++
+		var items []string
+		for !p.peekPunct("]") {
+			v, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		p.pos++
+		return "[" + strings.Join(items, ",") + "]", nil
+	case t.kind == tokenPunct && t.text == "{":
+		p.pos++
+		var fields []argumentNode
+		for !p.peekPunct("}") {
+			if !p.peekKind(tokenName) {
+				return "", fmt.Errorf("graphql: expected object field name at token %d", p.pos)
+			}
+			name := p.next().text
+			if err := p.expectPunct(":"); err != nil {
+				return "", err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, argumentNode{name: name, value: v})
+		}
+		p.pos++
+		sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+		parts := make([]string, len(fields))
+		for i, f := range fields {
+			parts[i] = f.name + ":" + f.value
+		}
+		return "{" + strings.Join(parts, ",") + "}", nil
+	case t.kind == tokenName || t.kind == tokenIntValue || t.kind == tokenFloatValue || t.kind == tokenStringValue:
+		p.pos++
+		return t.text, nil
+	default:
+		return "", fmt.Errorf("graphql: unexpected token %q at %d", t.text, p.pos)
+	}
+}
+
+// parseVariableDefinitions consumes "(...)" after an operation name and
+// re-renders it without reordering it: each definition's default value is
+// canonicalized via parseValue, but the definitions themselves keep their
+// original order since later ones may shadow or build on earlier syntax in
+// ways this lightweight parser doesn't attempt to reason about.
+func (p *parser) parseVariableDefinitions() (string, error) {
+	if err := p.expectPunct("("); err != nil {
+		return "", err
+	}
+	var parts []string
+	for !p.peekPunct(")") {
+		if p.peek() == nil {
+			return "", fmt.Errorf("graphql: unterminated variable definitions")
+		}
+		if p.peekPunct("=") {
+			p.pos++
+			value, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, "=", value)
+			continue
+		}
+		parts = append(parts, p.next().text)
+	}
+	p.pos++ // consume ")"
+	return "(" + strings.Join(parts, " ") + ")", nil
+}
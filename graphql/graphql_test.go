@@ -0,0 +1,126 @@
+package graphql
+
+import "testing"
+
+func TestParseDetectsOperationType(t *testing.T) {
+	cases := map[string]OperationType{
+		"{ viewer { id } }":                    OperationQuery,
+		"query { viewer { id } }":              OperationQuery,
+		"query GetViewer { viewer { id } }":    OperationQuery,
+		"mutation { createUser(name: \"a\") }": OperationMutation,
+		"subscription { commentAdded { id } }": OperationSubscription,
+	}
+	for query, want := range cases {
+		op, err := Parse(query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", query, err)
+		}
+		if op.Type != want {
+			t.Fatalf("Parse(%q).Type = %q, want %q", query, op.Type, want)
+		}
+	}
+}
+
+func TestIsMutationOrSubscription(t *testing.T) {
+	mutation, err := Parse("mutation { createUser(name: \"a\") }")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !IsMutationOrSubscription(mutation) {
+		t.Fatalf("expected mutation to be flagged")
+	}
+
+	query, err := Parse("{ viewer { id } }")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if IsMutationOrSubscription(query) {
+		t.Fatalf("expected plain query not to be flagged")
+	}
+}
+
+func TestParseCanonicalFormIgnoresWhitespaceAndFieldOrder(t *testing.T) {
+	a, err := Parse(`
+		# fetch a user's profile
+		query GetUser {
+			user(id: "1", active: true) {
+				name
+				id
+			}
+		}
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	b, err := Parse(`query GetUser{user(active:true,id:"1"){id name}}`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if a.Canonical != b.Canonical {
+		t.Fatalf("expected equivalent queries to canonicalize identically:\n%s\nvs\n%s", a.Canonical, b.Canonical)
+	}
+}
+
+func TestParseCanonicalFormDiffersOnSemanticChange(t *testing.T) {
+	a, err := Parse(`{ user(id: "1") { name } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	b, err := Parse(`{ user(id: "2") { name } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if a.Canonical == b.Canonical {
+		t.Fatalf("expected queries with different arguments to canonicalize differently")
+	}
+}
+
+func TestParseCanonicalFormSortsObjectValueFields(t *testing.T) {
+	a, err := Parse(`{ search(filter: {status: "open", owner: "me"}) { id } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	b, err := Parse(`{ search(filter: {owner: "me", status: "open"}) { id } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if a.Canonical != b.Canonical {
+		t.Fatalf("expected object-value argument to canonicalize regardless of key order:\n%s\nvs\n%s", a.Canonical, b.Canonical)
+	}
+}
+
+func TestParseCanonicalFormOrdersDuplicateInlineFragmentsByContent(t *testing.T) {
+	a, err := Parse(`{ node { ... on User { a } ... on User { b } } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	b, err := Parse(`{ node { ... on User { b } ... on User { a } } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if a.Canonical != b.Canonical {
+		t.Fatalf("expected duplicate same-type inline fragments to canonicalize regardless of source order:\n%s\nvs\n%s", a.Canonical, b.Canonical)
+	}
+}
+
+func TestParseCanonicalFormOrdersDuplicateFieldsByContent(t *testing.T) {
+	a, err := Parse(`{ user { friends(first: 1) { id } friends(first: 2) { id } } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	b, err := Parse(`{ user { friends(first: 2) { id } friends(first: 1) { id } } }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if a.Canonical != b.Canonical {
+		t.Fatalf("expected duplicate same-name fields to canonicalize regardless of source order:\n%s\nvs\n%s", a.Canonical, b.Canonical)
+	}
+}
+
+func TestParseRejectsMalformedQuery(t *testing.T) {
+	if _, err := Parse(`{ user(id: "1" }`); err == nil {
+		t.Fatalf("expected an error for an unbalanced argument list")
+	}
+}
@@ -0,0 +1,141 @@
+package capsulecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spdeepak/capsulecache/graphql"
+)
+
+// GraphQLParser parses a GraphQL query document, returning its operation
+// type and a canonical rendering suitable for hashing into a cache key.
+// Callers who need full spec compliance (fragments, schema-aware directives,
+// etc.) can plug in their own parser instead of the lightweight one in
+// capsulecache/graphql.
+type GraphQLParser func(query string) (graphql.Operation, error)
+
+// GraphQLKeyConfig configures GraphQLKeyGenerator.
+type GraphQLKeyConfig struct {
+	// ContentTypes lists the Content-Type values (matched by prefix, so
+	// "application/json; charset=utf-8" matches "application/json") that
+	// identify a GraphQL request. Defaults to "application/json" and
+	// "application/graphql-response+json".
+	ContentTypes []string
+	// PathPrefixes, if non-empty, additionally restricts GraphQLKeyGenerator
+	// to requests whose URL path starts with one of these prefixes (e.g.
+	// "/graphql"). Leave empty to match on Content-Type alone.
+	PathPrefixes []string
+	// Parser parses the query's operation type and canonical form. Defaults
+	// to graphql.Parse.
+	Parser GraphQLParser
+}
+
+// graphQLEnvelope is the standard GraphQL-over-HTTP POST body, per
+// https://graphql.org/learn/serving-over-http/.
+type graphQLEnvelope struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables"`
+	OperationName string          `json:"operationName"`
+}
+
+// GraphQLKeyGenerator returns a KeyGenerator for GraphQL endpoints. It
+// canonicalizes the query and variables so that semantically identical
+// requests share a cache key regardless of whitespace, field/argument
+// order, or comments, and it returns "" (bypassing the cache) for anything
+// that doesn't parse as a plain query, so mutations and subscriptions are
+// never cached. It consumes r.Body via io.TeeReader and restores it
+// afterwards, the same way AdvancedKeyGenerator does, so downstream
+// handlers still see the original body.
+func GraphQLKeyGenerator(cfg GraphQLKeyConfig) func(*http.Request) string {
+	contentTypes := cfg.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = []string{"application/json", "application/graphql-response+json"}
+	}
+	parse := cfg.Parser
+	if parse == nil {
+		parse = graphql.Parse
+	}
+
+	return func(r *http.Request) string {
+		if !isGraphQLRequest(r, contentTypes, cfg.PathPrefixes) || r.Body == nil {
+			return ""
+		}
+
+		var buf bytes.Buffer
+		tee := io.TeeReader(r.Body, &buf)
+		raw, err := io.ReadAll(tee)
+		r.Body.Close()
+		r.Body = io.NopCloser(&buf) // restore body for downstream
+
+		if err != nil {
+			return ""
+		}
+
+		var envelope graphQLEnvelope
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			return ""
+		}
+
+		op, err := parse(envelope.Query)
+		if err != nil || op.Type != graphql.OperationQuery {
+			return ""
+		}
+
+		// encoding/json already marshals map keys (at every nesting level)
+		// in sorted order, so re-marshalling variables through a generic
+		// map canonicalizes them regardless of how they were ordered in the
+		// request body.
+		canonicalVars, err := canonicalizeVariables(envelope.Variables)
+		if err != nil {
+			return ""
+		}
+
+		hash := sha256.Sum256([]byte(op.Canonical + "|" + canonicalVars + "|" + envelope.OperationName))
+		return "cache:graphql:" + hex.EncodeToString(hash[:])
+	}
+}
+
+// canonicalizeVariables re-marshals raw (a JSON object or null/absent) via a
+// generic map so nested object keys are sorted consistently.
+func canonicalizeVariables(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "null", nil
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(generic)
+	if err != nil {
+		return "", err
+	}
+	return string(canonical), nil
+}
+
+func isGraphQLRequest(r *http.Request, contentTypes, pathPrefixes []string) bool {
+	if len(pathPrefixes) > 0 {
+		matched := false
+		for _, prefix := range pathPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	for _, ct := range contentTypes {
+		if strings.HasPrefix(contentType, ct) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package capsulecache
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLKeyGeneratorCanonicalizesEquivalentQueries(t *testing.T) {
+	keyGen := GraphQLKeyGenerator(GraphQLKeyConfig{})
+
+	a := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query":"query GetUser { user(id: \"1\") { name id } }","variables":{"b":2,"a":1},"operationName":"GetUser"}`,
+	))
+	a.Header.Set("Content-Type", "application/json")
+
+	b := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query":"query GetUser{user(id:\"1\"){id name}}","variables":{"a":1,"b":2},"operationName":"GetUser"}`,
+	))
+	b.Header.Set("Content-Type", "application/json")
+
+	keyA := keyGen(a)
+	keyB := keyGen(b)
+	if keyA == "" || keyB == "" {
+		t.Fatalf("expected non-empty keys, got %q and %q", keyA, keyB)
+	}
+	if keyA != keyB {
+		t.Fatalf("expected equivalent GraphQL requests to share a cache key: %q vs %q", keyA, keyB)
+	}
+}
+
+func TestGraphQLKeyGeneratorRejectsMutations(t *testing.T) {
+	keyGen := GraphQLKeyGenerator(GraphQLKeyConfig{})
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query":"mutation { createUser(name: \"a\") { id } }"}`,
+	))
+	req.Header.Set("Content-Type", "application/json")
+
+	if key := keyGen(req); key != "" {
+		t.Fatalf("expected mutations to bypass the cache, got key %q", key)
+	}
+}
+
+func TestGraphQLKeyGeneratorRestoresBody(t *testing.T) {
+	keyGen := GraphQLKeyGenerator(GraphQLKeyConfig{})
+
+	body := `{"query":"{ viewer { id } }"}`
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	keyGen(req)
+
+	got, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("reading restored body: %v", err)
+	}
+	if string(got) != body {
+		t.Fatalf("expected body to be restored unchanged, got %q", got)
+	}
+}
+
+func TestGraphQLKeyGeneratorIgnoresNonGraphQLRequests(t *testing.T) {
+	keyGen := GraphQLKeyGenerator(GraphQLKeyConfig{PathPrefixes: []string{"/graphql"}})
+
+	req := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"query":"{ viewer { id } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	if key := keyGen(req); key != "" {
+		t.Fatalf("expected requests outside PathPrefixes to bypass, got key %q", key)
+	}
+}
+
+func TestGraphQLKeyGeneratorDiffersOnDifferentVariables(t *testing.T) {
+	keyGen := GraphQLKeyGenerator(GraphQLKeyConfig{})
+
+	a := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`,
+	))
+	a.Header.Set("Content-Type", "application/json")
+	b := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":"2"}}`,
+	))
+	b.Header.Set("Content-Type", "application/json")
+
+	if keyGen(a) == keyGen(b) {
+		t.Fatalf("expected different variables to produce different cache keys")
+	}
+}
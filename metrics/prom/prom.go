@@ -0,0 +1,74 @@
+// Package prom implements cache.MetricsRecorder on top of
+// github.com/prometheus/client_golang, so capsulecache's hit/miss/store/
+// evict/revalidate events show up as standard Prometheus metrics without
+// callers having to write the wiring themselves.
+package prom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Recorder is a cache.MetricsRecorder backed by Prometheus collectors.
+type Recorder struct {
+	requestsTotal       *prometheus.CounterVec
+	bytesStored         prometheus.Counter
+	evictionsTotal      *prometheus.CounterVec
+	revalidationSeconds prometheus.Histogram
+}
+
+// NewRecorder creates a Recorder and registers its collectors with reg. Pass
+// prometheus.DefaultRegisterer to use the global registry.
+func NewRecorder(reg prometheus.Registerer) *Recorder {
+	r := &Recorder{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "capsulecache_requests_total",
+			Help: "Total number of requests handled by the cache middleware, by outcome.",
+		}, []string{"status"}),
+		bytesStored: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "capsulecache_bytes_stored",
+			Help: "Total bytes written to the cache store.",
+		}),
+		evictionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "capsulecache_evictions_total",
+			Help: "Total number of cache entries removed before a later lookup would have found them stale on their own, by reason.",
+		}, []string{"reason"}),
+		revalidationSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "capsulecache_revalidation_seconds",
+			Help:    "Duration of upstream revalidation requests.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+	reg.MustRegister(r.requestsTotal, r.bytesStored, r.evictionsTotal, r.revalidationSeconds)
+	return r
+}
+
+// OnHit implements cache.MetricsRecorder.
+func (r *Recorder) OnHit(key string, stale bool) {
+	if stale {
+		r.requestsTotal.WithLabelValues("hit_stale").Inc()
+		return
+	}
+	r.requestsTotal.WithLabelValues("hit").Inc()
+}
+
+// OnMiss implements cache.MetricsRecorder.
+func (r *Recorder) OnMiss(key string) {
+	r.requestsTotal.WithLabelValues("miss").Inc()
+}
+
+// OnStore implements cache.MetricsRecorder.
+func (r *Recorder) OnStore(key string, bytes int64) {
+	r.bytesStored.Add(float64(bytes))
+}
+
+// OnEvict implements cache.MetricsRecorder.
+func (r *Recorder) OnEvict(key string, bytes int64, reason string) {
+	r.evictionsTotal.WithLabelValues(reason).Inc()
+}
+
+// OnRevalidate implements cache.MetricsRecorder.
+func (r *Recorder) OnRevalidate(key string, status int, dur time.Duration) {
+	r.revalidationSeconds.Observe(dur.Seconds())
+}
@@ -0,0 +1,63 @@
+package prom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(t *testing.T, vec *prometheus.CounterVec, labels ...string) float64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	if err := vec.WithLabelValues(labels...).Write(metric); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestRecorderTracksRequestsAndBytesAndEvictions(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.OnHit("k", false)
+	recorder.OnHit("k", true)
+	recorder.OnMiss("k")
+	recorder.OnStore("k", 128)
+	recorder.OnEvict("k", 64, "quota")
+	recorder.OnRevalidate("k", 200, 10*time.Millisecond)
+
+	if got := counterValue(t, recorder.requestsTotal, "hit"); got != 1 {
+		t.Fatalf("expected 1 hit, got %v", got)
+	}
+	if got := counterValue(t, recorder.requestsTotal, "hit_stale"); got != 1 {
+		t.Fatalf("expected 1 stale hit, got %v", got)
+	}
+	if got := counterValue(t, recorder.requestsTotal, "miss"); got != 1 {
+		t.Fatalf("expected 1 miss, got %v", got)
+	}
+	if got := counterValue(t, recorder.evictionsTotal, "quota"); got != 1 {
+		t.Fatalf("expected 1 quota eviction, got %v", got)
+	}
+
+	metricFamilies, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var sawBytes, sawRevalidation bool
+	for _, family := range metricFamilies {
+		switch family.GetName() {
+		case "capsulecache_bytes_stored":
+			sawBytes = family.GetMetric()[0].GetCounter().GetValue() == 128
+		case "capsulecache_revalidation_seconds":
+			sawRevalidation = family.GetMetric()[0].GetHistogram().GetSampleCount() == 1
+		}
+	}
+	if !sawBytes {
+		t.Fatalf("expected capsulecache_bytes_stored to report 128 bytes")
+	}
+	if !sawRevalidation {
+		t.Fatalf("expected capsulecache_revalidation_seconds to have one observation")
+	}
+}
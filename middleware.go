@@ -2,6 +2,7 @@ package capsulecache
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"time"
@@ -11,8 +12,16 @@ import (
 )
 
 // NewCacheMiddleware returns middleware that caches GET/HEAD responses with quotas,
-// SWR (Stale while revalidate) refreshes and sensible defaults.
+// SWR (Stale while revalidate) refreshes and sensible defaults. Responses are
+// streamed straight through to the client and, independently, straight into
+// the store via Store.BeginWrite, so the hit and miss paths never buffer a
+// full response in the middleware's own memory no matter how large the body
+// is (synchronous revalidation of a rotten entry does buffer it).
+// POST requests are cached too when cfg.KeyGenerator returns a non-empty key
+// for them.
 // The middleware uses singleflight to avoid thundering-herd during SWR refreshes.
+// When cfg.RespectCacheControl is set, per-entry freshness/revalidation is
+// instead derived from RFC 7234 request/response headers; see cachecontrol.go.
 func NewCacheMiddleware(store cache.Store, cfg *Config) func(next http.Handler) http.Handler {
 	if cfg == nil {
 		cfg = DefaultConfig
@@ -24,8 +33,11 @@ func NewCacheMiddleware(store cache.Store, cfg *Config) func(next http.Handler)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
-			// Only cache GET/HEAD requests
-			if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			// POST is allowed through to the key generator too (e.g. for
+			// GraphQL-over-HTTP, which is POST by convention) but only ever
+			// enters the cache path if the generator opts it in by returning
+			// a non-empty key; every other mutating method is never cached.
+			if request.Method != http.MethodGet && request.Method != http.MethodHead && request.Method != http.MethodPost {
 				next.ServeHTTP(responseWriter, request)
 				return
 			}
@@ -38,116 +50,449 @@ func NewCacheMiddleware(store cache.Store, cfg *Config) func(next http.Handler)
 				return
 			}
 
-			// Check if response is already cached
-			responseCacheEntry, cacheHit := store.Get(cacheKey)
-			if cacheHit && responseCacheEntry != nil && !responseCacheEntry.IsRotten() {
+			// Check if response is already cached. OpenReader leaves Body
+			// unset, so checking freshness/Vary never has to load a
+			// potentially large stored body into memory.
+			responseCacheEntry, reader, cacheHit := store.OpenReader(cacheKey)
+
+			if cfg.RespectCacheControl {
+				// A Vary mismatch means the stored entry is for a different
+				// representation of this URL; look for the secondary variant
+				// slot instead of treating the URL itself as a miss.
+				if cacheHit && len(responseCacheEntry.Vary) > 0 && !varyMatches(&responseCacheEntry, request) {
+					_ = reader.Close()
+					cacheKey = varyVariantKey(cacheKey, responseCacheEntry.Vary, request.Header)
+					responseCacheEntry, reader, cacheHit = store.OpenReader(cacheKey)
+					if cacheHit && !varyMatches(&responseCacheEntry, request) {
+						_ = reader.Close()
+						reader, cacheHit = nil, false
+					}
+				}
+				// Client asked to skip the cache outright.
+				if cacheHit && requestBypassesCache(request) {
+					_ = reader.Close()
+					reader, cacheHit = nil, false
+				}
+			}
+
+			if cacheHit && !responseCacheEntry.IsRotten() {
+				stale := responseCacheEntry
+				recordHit(cfg, cacheKey, stale.IsStale())
 				// Serve cached response -> must set headers BEFORE WriteHeader
-				// Start with a fresh header map so we don't mutate stored headers
-				for headerKey, headerValues := range responseCacheEntry.Headers {
+				for headerKey, headerValues := range stale.Headers {
 					for _, headerValue := range headerValues {
 						responseWriter.Header().Add(headerKey, headerValue)
 					}
 				}
 				responseWriter.Header().Set("X-Cache-Status", "HIT")
-				if responseCacheEntry.IsStale() {
+				if stale.IsStale() {
 					responseWriter.Header().Set("X-Cache-Stale", "YES")
 					// trigger background SWR refresh via singleflight since the cache is stale
-					go func(ctx context.Context, cacheKey string, req *http.Request) {
+					go func(cacheKey string, req *http.Request, stale cache.ResponseCacheEntry) {
 						// singleflight ensures only one background refresh for this cacheKey
 						_, _, _ = singleFlight.Do(cacheKey, func() (interface{}, error) {
 							// create a fresh request clone with background context
 							reqClone := req.Clone(context.Background())
-
-							// Use a recorder that discards writes (we only want capture for cache)
-							// Using a dummy response writer that implements minimal interface.
-							dw := &discardResponseWriter{header: make(http.Header)}
-							rec := NewResponseRecorder(dw, cfg.MaxBodyBytes)
-
-							defer func() {
-								// recover handler panics in refresh to avoid crashing goroutine
-								_ = recover()
-							}()
-
-							next.ServeHTTP(rec, reqClone)
-
-							// If the response is cacheable, cache it
-							if cfg.ShouldCache(rec.StatusCode()) && !rec.capReached {
-								response := &cache.ResponseCacheEntry{
-									StatusCode: rec.StatusCode(),
-									Headers:    cfg.StripHeaders(rec.Header().Clone()),
-									Body:       append([]byte(nil), rec.Body()...), // copy
-									CreatedAt:  time.Now(),
-									TTL:        cfg.DefaultTTL,
-									SWR:        cfg.DefaultSWR,
-								}
-								err := store.Set(cacheKey, response)
-								if err != nil {
-									slog.Error("Failed to cache response during single flight", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
-								}
+							if cfg.RespectCacheControl {
+								setConditionalHeaders(reqClone, &stale)
 							}
+
+							refreshCached(next, cfg, store, cacheKey, reqClone, req, &stale)
 							return nil, nil
 						})
-					}(request.Context(), cacheKey, request)
+					}(cacheKey, request, stale)
 				} else {
 					responseWriter.Header().Set("X-Cache-Stale", "NO")
 				}
 
-				// Write the cached response to client
-				responseWriter.WriteHeader(responseCacheEntry.StatusCode)
-				if len(responseCacheEntry.Body) > 0 {
-					_, _ = responseWriter.Write(responseCacheEntry.Body)
+				// The client's own conditional request already names the
+				// representation we'd serve: answer 304 with no body
+				// instead of re-streaming it, per RFC 7234's request-header
+				// validators (If-None-Match / If-Modified-Since).
+				if requestValidatorsMatch(request, &stale) {
+					if reader != nil {
+						_ = reader.Close()
+					}
+					responseWriter.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				// Write the cached response to client, streaming the body
+				// straight from the store.
+				responseWriter.WriteHeader(stale.StatusCode)
+				if reader != nil {
+					_, _ = io.Copy(responseWriter, reader)
+					_ = reader.Close()
+				}
+				return
+			}
+			if reader != nil {
+				_ = reader.Close()
+			}
+
+			// Rotten entry within its stale-if-error window: try to revalidate
+			// synchronously, but fall back to serving the rotten entry rather
+			// than an upstream error. This path makes a blocking upstream call
+			// already, so (unlike the hot paths above) it buffers the body
+			// rather than streaming it.
+			if cfg.RespectCacheControl && cacheHit && responseCacheEntry.IsRotten() && withinStaleIfError(&responseCacheEntry, time.Now()) {
+				if full, ok := store.Get(cacheKey); ok {
+					serveStaleIfErrorOrRefresh(next, cfg, store, cacheKey, request, responseWriter, full)
+				}
+				return
+			}
+
+			// Rotten entry with no stale-if-error allowance (e.g. no-cache
+			// or must-revalidate) but still carrying a validator: revalidate
+			// against the origin with it rather than treating this as a cold
+			// miss, so the stored ETag/Last-Modified is actually used.
+			if cfg.RespectCacheControl && cacheHit && responseCacheEntry.IsRotten() && (responseCacheEntry.ETag != "" || responseCacheEntry.LastModified != "") {
+				if full, ok := store.Get(cacheKey); ok {
+					revalidateBeforeReuse(next, cfg, store, cacheKey, request, responseWriter, full)
 				}
 				return
-			} else if !cacheHit {
+			}
+
+			if !cacheHit {
 				responseWriter.Header().Set("X-Cache-Status", "MISS")
+				recordMiss(cfg, cacheKey)
 			}
 
-			// Cache miss/rotten path: execute handler and capture response
-			// Protect against panics so we can still flush or at least return a 500
-			responseRecorder := NewResponseRecorder(responseWriter, cfg.MaxBodyBytes)
+			// Cache miss/rotten path: stream the handler's response straight
+			// to the client while spooling it into the store.
+			recorder := NewStreamingRecorder(responseWriter, cacheWriterOpener(cfg, store, cacheKey, request), commitErrorLogger(cacheKey, request))
 			defer func() {
 				if p := recover(); p != nil {
+					recorder.Abort()
 					// Best-effort: return 500 if nothing was written and avoid crashing the server
-					if !responseRecorder.written {
+					if !recorder.HeaderWritten() {
 						http.Error(responseWriter, "internal server error", http.StatusInternalServerError)
 					}
 				}
 			}()
 
-			// Actually call next handler and let responseRecorder capture
-			next.ServeHTTP(responseRecorder, request)
-
-			// Determine if we can/should cache
-			status := responseRecorder.StatusCode()
-			// If body exceeded cap, do not cache
-			if cfg.ShouldCache(status) && !responseRecorder.capReached {
-				// Clone headers and strip hop-by-hop
-				hdrCopy := responseRecorder.Header().Clone()
-				clean := cfg.StripHeaders(hdrCopy)
-
-				newEntry := &cache.ResponseCacheEntry{
-					StatusCode: status,
-					Headers:    clean,
-					Body:       append([]byte(nil), responseRecorder.Body()...), // copy
-					CreatedAt:  time.Now(),
-					TTL:        cfg.DefaultTTL,
-					SWR:        cfg.DefaultSWR,
-				}
-				// Store asynchronously so we don't block the client
-				go func(cacheKey string, newEntry *cache.ResponseCacheEntry) {
-					// recover to avoid uncaught goroutine panic
-					defer func() { _ = recover() }()
-					err := store.Set(cacheKey, newEntry)
-					if err != nil {
-						slog.Error("Failed to cache response", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
-					}
-				}(cacheKey, newEntry)
+			next.ServeHTTP(recorder, request)
+			recorder.Finish()
+		})
+	}
+}
+
+// refreshCached runs next against reqClone to revalidate/refresh the stale
+// entry at cacheKey, storing the result without writing anything back to the
+// original client (the background SWR refresh isn't observed by anyone).
+func refreshCached(next http.Handler, cfg *Config, store cache.Store, cacheKey string, reqClone, origReq *http.Request, stale *cache.ResponseCacheEntry) {
+	defer func() {
+		// recover handler panics in refresh to avoid crashing goroutine
+		_ = recover()
+	}()
+
+	dw := &discardResponseWriter{header: make(http.Header)}
+	recorder := NewStreamingRecorder(dw, cacheWriterOpener(cfg, store, cacheKey, reqClone), commitErrorLogger(cacheKey, origReq))
+
+	start := time.Now()
+	next.ServeHTTP(recorder, reqClone)
+	recordRevalidate(cfg, cacheKey, recorder.StatusCode(), time.Since(start))
+
+	if cfg.RespectCacheControl && recorder.StatusCode() == http.StatusNotModified {
+		refreshOn304(cfg, store, cacheKey, stale, recorder.Header(), origReq, time.Now(), stale.Tags)
+		return
+	}
+
+	recorder.Finish()
+}
+
+// cacheWriterOpener builds the onHeader callback a StreamingRecorder uses to
+// decide, once a response's status/headers are known, whether to open a
+// cache.BlobWriter for it.
+func cacheWriterOpener(cfg *Config, store cache.Store, cacheKey string, request *http.Request) func(status int, header http.Header) cache.BlobWriter {
+	return func(status int, header http.Header) cache.BlobWriter {
+		if status == http.StatusNotModified || !cfg.ShouldCache(status) {
+			return nil
+		}
+
+		hdrCopy := cfg.StripHeaders(header)
+		tags := tagsFor(cfg, request, status, hdrCopy)
+		meta, ok := buildEntry(cfg, status, hdrCopy, nil, request, time.Now(), tags)
+		if !ok {
+			return nil
+		}
+
+		writer, err := store.BeginWrite(cacheKey, *meta)
+		if err != nil {
+			slog.Error("Failed to open cache writer", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+			return nil
+		}
+		if cfg.Metrics == nil {
+			return writer
+		}
+		return &meteredBlobWriter{inner: writer, cacheKey: cacheKey, cfg: cfg}
+	}
+}
+
+// meteredBlobWriter tallies bytes written so cfg.Metrics.OnStore gets an
+// accurate count on Commit, without requiring the whole body to be
+// buffered. It's the middleware-local equivalent of cache.observableStore's
+// own byte-tallying writer, kept separate since cfg.Metrics is independent
+// of whichever Store implementation is in use.
+type meteredBlobWriter struct {
+	inner    cache.BlobWriter
+	cacheKey string
+	cfg      *Config
+	bytes    int64
+}
+
+func (w *meteredBlobWriter) Write(p []byte) (int, error) {
+	n, err := w.inner.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *meteredBlobWriter) Commit() error {
+	if err := w.inner.Commit(); err != nil {
+		return err
+	}
+	recordStore(w.cfg, w.cacheKey, w.bytes)
+	return nil
+}
+
+func (w *meteredBlobWriter) Abort() error {
+	return w.inner.Abort()
+}
+
+// recordHit, recordMiss, recordStore and recordRevalidate forward to
+// cfg.Metrics, which is optional, so every call site stays a one-liner
+// instead of repeating the nil check.
+func recordHit(cfg *Config, cacheKey string, stale bool) {
+	if cfg.Metrics != nil {
+		cfg.Metrics.OnHit(cacheKey, stale)
+	}
+}
+
+func recordMiss(cfg *Config, cacheKey string) {
+	if cfg.Metrics != nil {
+		cfg.Metrics.OnMiss(cacheKey)
+	}
+}
+
+func recordStore(cfg *Config, cacheKey string, bytes int64) {
+	if cfg.Metrics != nil {
+		cfg.Metrics.OnStore(cacheKey, bytes)
+	}
+}
+
+func recordRevalidate(cfg *Config, cacheKey string, status int, dur time.Duration) {
+	if cfg.Metrics != nil {
+		cfg.Metrics.OnRevalidate(cacheKey, status, dur)
+	}
+}
+
+// commitErrorLogger returns a StreamingRecorder onCommitErr callback that
+// logs with the same fields the rest of the middleware uses.
+func commitErrorLogger(cacheKey string, request *http.Request) func(error) {
+	return func(err error) {
+		slog.Error("Failed to cache response", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+	}
+}
+
+// buildEntry builds the entry to store for a freshly fetched response. When
+// cfg.RespectCacheControl is set, freshness/Vary/validators come from the
+// response's own headers (see buildRFC7234Entry); otherwise the legacy
+// always-use-defaults behavior is preserved. body may be nil: callers that
+// stream the body in via Store.BeginWrite don't have it yet, and the stored
+// entry's Body is filled in by the BlobWriter on Commit regardless of what's
+// passed here. ok is false when the response must not be cached at all.
+func buildEntry(cfg *Config, status int, headers http.Header, body []byte, request *http.Request, now time.Time, tags []string) (*cache.ResponseCacheEntry, bool) {
+	if cfg.RespectCacheControl {
+		return buildRFC7234Entry(headers, status, body, request, now, cfg.DefaultSWR, tags)
+	}
+	return &cache.ResponseCacheEntry{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       body,
+		CreatedAt:  now,
+		TTL:        cfg.DefaultTTL,
+		SWR:        cfg.DefaultSWR,
+		Tags:       tags,
+	}, true
+}
+
+// tagsFor runs cfg.TagExtractor, if set, to derive invalidation tags for a
+// response identified by its status code and (already stripped) headers.
+func tagsFor(cfg *Config, request *http.Request, statusCode int, header http.Header) []string {
+	if cfg.TagExtractor == nil {
+		return nil
+	}
+	return cfg.TagExtractor(request, statusCode, header)
+}
+
+// setConditionalHeaders adds If-None-Match/If-Modified-Since to req from the
+// validators stored on entry, so the upstream can reply 304 Not Modified.
+func setConditionalHeaders(req *http.Request, entry *cache.ResponseCacheEntry) {
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// withinStaleIfError reports whether a rotten entry is still within its
+// RFC 7234 stale-if-error window and may be served if revalidation fails.
+func withinStaleIfError(entry *cache.ResponseCacheEntry, now time.Time) bool {
+	return entry.StaleIfError > 0 && now.Sub(entry.CreatedAt) <= entry.TTL+entry.SWR+entry.StaleIfError
+}
+
+// serveStaleIfErrorOrRefresh synchronously revalidates a rotten entry that is
+// still within its stale-if-error window. If the upstream call panics or
+// errors, it serves the rotten entry. Otherwise it caches and serves the
+// upstream's answer (a refreshed 304 or a replacing 2xx).
+func serveStaleIfErrorOrRefresh(next http.Handler, cfg *Config, store cache.Store, cacheKey string, request *http.Request, responseWriter http.ResponseWriter, stale *cache.ResponseCacheEntry) {
+	revalidate(next, cfg, store, cacheKey, request, responseWriter, stale, true)
+}
+
+// revalidateBeforeReuse synchronously revalidates a rotten entry that still
+// carries stored validators (e.g. a no-cache or must-revalidate entry) but
+// has no stale-if-error allowance of its own. Unlike serveStaleIfErrorOrRefresh,
+// a failed revalidation is never masked by serving the rotten entry: no-cache
+// means the client must never see unvalidated content, even on upstream
+// failure.
+func revalidateBeforeReuse(next http.Handler, cfg *Config, store cache.Store, cacheKey string, request *http.Request, responseWriter http.ResponseWriter, stale *cache.ResponseCacheEntry) {
+	revalidate(next, cfg, store, cacheKey, request, responseWriter, stale, false)
+}
+
+// revalidate sends a conditional request built from stale's stored
+// validators and either serves a refreshed/304 result or, when
+// serveStaleOnFailure is set, falls back to the rotten entry on upstream
+// failure (panic or 5xx).
+func revalidate(next http.Handler, cfg *Config, store cache.Store, cacheKey string, request *http.Request, responseWriter http.ResponseWriter, stale *cache.ResponseCacheEntry, serveStaleOnFailure bool) {
+	reqClone := request.Clone(request.Context())
+	setConditionalHeaders(reqClone, stale)
+
+	responseRecorder := NewResponseRecorder(responseWriter, 0)
+	start := time.Now()
+	panicked := func() (didPanic bool) {
+		defer func() {
+			if p := recover(); p != nil {
+				didPanic = true
 			}
+		}()
+		next.ServeHTTP(responseRecorder, reqClone)
+		return false
+	}()
+	if !panicked {
+		recordRevalidate(cfg, cacheKey, responseRecorder.StatusCode(), time.Since(start))
+	}
 
-			// Finally, flush recorded response to the client
+	if panicked || responseRecorder.StatusCode() >= http.StatusInternalServerError {
+		if !serveStaleOnFailure {
+			// No stale-if-error allowance: never mask the failure with
+			// unvalidated content.
+			recordMiss(cfg, cacheKey)
+			responseWriter.Header().Set("X-Cache-Status", "MISS")
+			if panicked {
+				http.Error(responseWriter, "bad gateway", http.StatusBadGateway)
+				return
+			}
 			responseRecorder.Flush()
-		})
+			return
+		}
+		recordHit(cfg, cacheKey, true)
+		responseWriter.Header().Set("X-Cache-Status", "HIT")
+		responseWriter.Header().Set("X-Cache-Stale", "YES")
+		for headerKey, headerValues := range stale.Headers {
+			for _, headerValue := range headerValues {
+				responseWriter.Header().Add(headerKey, headerValue)
+			}
+		}
+		responseWriter.WriteHeader(stale.StatusCode)
+		if len(stale.Body) > 0 {
+			_, _ = responseWriter.Write(stale.Body)
+		}
+		return
+	}
+
+	if responseRecorder.StatusCode() == http.StatusNotModified {
+		refreshed := refreshOn304(cfg, store, cacheKey, stale, responseRecorder.Header(), request, time.Now(), stale.Tags)
+		recordHit(cfg, cacheKey, false)
+		responseWriter.Header().Set("X-Cache-Status", "HIT")
+		responseWriter.Header().Set("X-Cache-Stale", "NO")
+		for headerKey, headerValues := range refreshed.Headers {
+			for _, headerValue := range headerValues {
+				responseWriter.Header().Add(headerKey, headerValue)
+			}
+		}
+		responseWriter.WriteHeader(refreshed.StatusCode)
+		if _, reader, ok := store.OpenReader(cacheKey); ok {
+			_, _ = io.Copy(responseWriter, reader)
+			_ = reader.Close()
+		}
+		return
+	}
+
+	responseWriter.Header().Set("X-Cache-Status", "MISS")
+	recordMiss(cfg, cacheKey)
+	if cfg.ShouldCache(responseRecorder.StatusCode()) {
+		hdrCopy := cfg.StripHeaders(responseRecorder.Header().Clone())
+		tags := tagsFor(cfg, request, responseRecorder.StatusCode(), hdrCopy)
+		newEntry, ok := buildEntry(cfg, responseRecorder.StatusCode(), hdrCopy, append([]byte(nil), responseRecorder.Body()...), request, time.Now(), tags)
+		if ok {
+			if err := store.Set(cacheKey, newEntry); err != nil {
+				slog.Error("Failed to cache response after stale-if-error revalidation", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+			} else {
+				recordStore(cfg, cacheKey, newEntry.Size())
+			}
+		}
+	}
+	responseRecorder.Flush()
+}
+
+// refreshOn304 rebuilds the cache entry for a validator hit: headers are
+// updated per RFC 7232 §4.1 and (when cfg.RespectCacheControl is set)
+// freshness is recomputed from the merged headers. The body is never read
+// into memory: it's streamed straight from the existing stored entry into a
+// new one under the same key via Store.BeginWrite, since a 304 guarantees
+// the representation is unchanged. Returns the new metadata (Body unset).
+func refreshOn304(cfg *Config, store cache.Store, cacheKey string, stale *cache.ResponseCacheEntry, freshHeaders http.Header, request *http.Request, now time.Time, tags []string) *cache.ResponseCacheEntry {
+	merged := mergeRevalidationHeaders(stale.Headers, freshHeaders)
+
+	var meta *cache.ResponseCacheEntry
+	if cfg.RespectCacheControl {
+		meta, _ = buildRFC7234Entry(merged, stale.StatusCode, nil, request, now, cfg.DefaultSWR, tags)
+	}
+	if meta == nil {
+		meta = &cache.ResponseCacheEntry{
+			StatusCode: stale.StatusCode,
+			Headers:    merged,
+			CreatedAt:  now,
+			TTL:        cfg.DefaultTTL,
+			SWR:        cfg.DefaultSWR,
+			Tags:       tags,
+		}
+	}
+
+	_, reader, ok := store.OpenReader(cacheKey)
+	if !ok {
+		if err := store.Set(cacheKey, meta); err != nil {
+			slog.Error("Failed to refresh cached response after revalidation", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+		}
+		return meta
+	}
+	defer reader.Close()
+
+	writer, err := store.BeginWrite(cacheKey, *meta)
+	if err != nil {
+		slog.Error("Failed to refresh cached response after revalidation", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+		return meta
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		_ = writer.Abort()
+		slog.Error("Failed to refresh cached response after revalidation", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
+		return meta
+	}
+	if err := writer.Commit(); err != nil {
+		slog.Error("Failed to refresh cached response after revalidation", slog.Any("cacheKey", cacheKey), slog.Any("error", err.Error()), slog.Any("request", request))
 	}
+	return meta
 }
 
 // Utility: discardResponseWriter
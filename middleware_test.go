@@ -1,8 +1,10 @@
 package capsulecache
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,7 +22,6 @@ func TestCacheMiddleware(t *testing.T) {
 		DefaultSWR:   1500 * time.Millisecond,
 		KeyGenerator: DefaultKeyGenerator,
 		ShouldCache:  func(statusCode int) bool { return statusCode == http.StatusOK },
-		MaxBodyBytes: 1024,
 		StripHeaders: stripHopByHop,
 	}
 	client := NewCacheMiddleware(cache, &config)(mux)
@@ -53,3 +54,335 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("fresh"))
 }
+
+func TestCacheMiddlewareRespectsCacheControl(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh"))
+	})
+
+	cache := cache2.NewInMemoryQuotaLRU(2)
+	config := Config{
+		DefaultTTL:          5 * time.Minute,
+		DefaultSWR:          1 * time.Minute,
+		KeyGenerator:        DefaultKeyGenerator,
+		ShouldCache:         func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders:        stripHopByHop,
+		RespectCacheControl: true,
+	}
+	client := NewCacheMiddleware(cache, &config)(mux)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	r1 := httptest.NewRecorder()
+	client.ServeHTTP(r1, req)
+	if r1.Header().Get("X-Cache-Status") != "MISS" {
+		t.Fatalf("expected MISS, got %s", r1.Header().Get("X-Cache-Status"))
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	r2 := httptest.NewRecorder()
+	client.ServeHTTP(r2, req)
+	if r2.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("expected HIT, got %s", r2.Header().Get("X-Cache-Status"))
+	}
+	if r2.Header().Get("X-Cache-Stale") != "NO" {
+		t.Fatalf("expected entry to still be fresh from max-age=1, got stale=%s", r2.Header().Get("X-Cache-Stale"))
+	}
+
+	// Cache-Control: max-age=1 should make the entry stale after just over a second,
+	// instead of the much larger DefaultTTL.
+	time.Sleep(1100 * time.Millisecond)
+	r3 := httptest.NewRecorder()
+	client.ServeHTTP(r3, req)
+	if r3.Header().Get("X-Cache-Stale") != "YES" {
+		t.Fatalf("expected entry to be stale per max-age=1, got stale=%s", r3.Header().Get("X-Cache-Stale"))
+	}
+}
+
+func TestCacheMiddlewareCachesGraphQLPostRequests(t *testing.T) {
+	var handlerCalls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		handlerCalls++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh"))
+	})
+
+	config := Config{
+		DefaultTTL:   1 * time.Minute,
+		DefaultSWR:   1 * time.Minute,
+		KeyGenerator: GraphQLKeyGenerator(GraphQLKeyConfig{}),
+		ShouldCache:  func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders: stripHopByHop,
+	}
+	client := NewCacheMiddleware(cache2.NewInMemoryQuotaLRU(2), &config)(mux)
+
+	body := `{"query":"{ viewer { id } }"}`
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	r1 := httptest.NewRecorder()
+	client.ServeHTTP(r1, newReq())
+	if r1.Header().Get("X-Cache-Status") != "MISS" {
+		t.Fatalf("expected MISS, got %s", r1.Header().Get("X-Cache-Status"))
+	}
+
+	r2 := httptest.NewRecorder()
+	client.ServeHTTP(r2, newReq())
+	if r2.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("expected HIT, got %s", r2.Header().Get("X-Cache-Status"))
+	}
+	if r2.Body.String() != "fresh" {
+		t.Fatalf("unexpected body: %s", r2.Body.String())
+	}
+	if handlerCalls != 1 {
+		t.Fatalf("expected the upstream handler to run once, got %d calls", handlerCalls)
+	}
+}
+
+func TestCacheMiddlewareServesClientConditionalGetAs304(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh"))
+	})
+
+	config := Config{
+		DefaultTTL:          5 * time.Minute,
+		DefaultSWR:          1 * time.Minute,
+		KeyGenerator:        DefaultKeyGenerator,
+		ShouldCache:         func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders:        stripHopByHop,
+		RespectCacheControl: true,
+	}
+	client := NewCacheMiddleware(cache2.NewInMemoryQuotaLRU(2), &config)(mux)
+
+	client.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	r2 := httptest.NewRecorder()
+	client.ServeHTTP(r2, req)
+
+	if r2.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", r2.Code)
+	}
+	if r2.Body.Len() != 0 {
+		t.Fatalf("expected empty body on 304, got %q", r2.Body.String())
+	}
+}
+
+func TestCacheMiddlewareRevalidatesNoCacheEntryWithStoredValidator(t *testing.T) {
+	var upstreamCalls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh"))
+	})
+
+	config := Config{
+		DefaultTTL:          5 * time.Minute,
+		DefaultSWR:          1 * time.Minute,
+		KeyGenerator:        DefaultKeyGenerator,
+		ShouldCache:         func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders:        stripHopByHop,
+		RespectCacheControl: true,
+	}
+	client := NewCacheMiddleware(cache2.NewInMemoryQuotaLRU(2), &config)(mux)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	r1 := httptest.NewRecorder()
+	client.ServeHTTP(r1, req)
+	if r1.Header().Get("X-Cache-Status") != "MISS" {
+		t.Fatalf("expected MISS, got %s", r1.Header().Get("X-Cache-Status"))
+	}
+	if upstreamCalls != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", upstreamCalls)
+	}
+
+	r2 := httptest.NewRecorder()
+	client.ServeHTTP(r2, req)
+	if upstreamCalls != 2 {
+		t.Fatalf("expected no-cache reuse to revalidate against the origin, got %d upstream calls", upstreamCalls)
+	}
+	if r2.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("expected HIT after a 304 revalidation, got %s", r2.Header().Get("X-Cache-Status"))
+	}
+	if r2.Header().Get("X-Cache-Stale") != "NO" {
+		t.Fatalf("expected stale=NO once revalidated, got %s", r2.Header().Get("X-Cache-Stale"))
+	}
+	if r2.Body.String() != "fresh" {
+		t.Fatalf("expected the stored body to be served, got %q", r2.Body.String())
+	}
+}
+
+func TestPurgeOnMutation(t *testing.T) {
+	cache := cache2.NewInMemoryQuotaLRU(2)
+	cache.Set("cache:GET:/users/42", &cache2.ResponseCacheEntry{Body: []byte("cached"), Tags: []string{"user:42"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/42", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := PurgeOnMutation(cache, func(r *http.Request) []string {
+		return []string{"user:42"}
+	})(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := cache.Get("cache:GET:/users/42"); ok {
+		t.Fatalf("expected cached entry tagged user:42 to be purged after mutation")
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	directives := parseCacheControl(`max-age=60, no-cache, must-revalidate, stale-while-revalidate=30`)
+	if directives.MaxAge == nil || *directives.MaxAge != 60 {
+		t.Fatalf("expected max-age=60, got %+v", directives.MaxAge)
+	}
+	if !directives.NoCache || !directives.MustRevalidate {
+		t.Fatalf("expected no-cache and must-revalidate to be set, got %+v", directives)
+	}
+	if directives.StaleWhileRevalidate == nil || *directives.StaleWhileRevalidate != 30 {
+		t.Fatalf("expected stale-while-revalidate=30, got %+v", directives.StaleWhileRevalidate)
+	}
+}
+
+func TestCacheMiddlewareStreamsLargeBodyToDiskStore(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 5*1024*1024)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(large)
+	})
+
+	store, err := cache2.NewDiskStore(t.TempDir(), 64*1024*1024)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer store.Close()
+
+	config := Config{
+		DefaultTTL:   1 * time.Second,
+		DefaultSWR:   1500 * time.Millisecond,
+		KeyGenerator: DefaultKeyGenerator,
+		ShouldCache:  func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders: stripHopByHop,
+	}
+	client := NewCacheMiddleware(store, &config)(mux)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	r1 := httptest.NewRecorder()
+	client.ServeHTTP(r1, req)
+	if r1.Header().Get("X-Cache-Status") != "MISS" {
+		t.Fatalf("expected MISS, got %s", r1.Header().Get("X-Cache-Status"))
+	}
+	if !bytes.Equal(r1.Body.Bytes(), large) {
+		t.Fatalf("unexpected response body length: %d", r1.Body.Len())
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	r2 := httptest.NewRecorder()
+	client.ServeHTTP(r2, req)
+	if r2.Header().Get("X-Cache-Status") != "HIT" {
+		t.Fatalf("expected HIT, got %s", r2.Header().Get("X-Cache-Status"))
+	}
+	if !bytes.Equal(r2.Body.Bytes(), large) {
+		t.Fatalf("unexpected cached response body length: %d", r2.Body.Len())
+	}
+}
+
+func TestStreamingRecorderTeesToClientAndBlobWriter(t *testing.T) {
+	store := cache2.NewInMemoryQuotaLRU(1)
+
+	underlying := httptest.NewRecorder()
+	var opened bool
+	recorder := NewStreamingRecorder(underlying, func(status int, header http.Header) cache2.BlobWriter {
+		opened = true
+		writer, err := store.BeginWrite("k", cache2.ResponseCacheEntry{StatusCode: status})
+		if err != nil {
+			t.Fatalf("BeginWrite: %v", err)
+		}
+		return writer
+	}, func(err error) {
+		t.Fatalf("unexpected commit error: %v", err)
+	})
+
+	recorder.WriteHeader(http.StatusOK)
+	recorder.Write([]byte("hello "))
+	recorder.Write([]byte("world"))
+	recorder.Finish()
+
+	if !opened {
+		t.Fatalf("expected onHeader to be called")
+	}
+	if underlying.Body.String() != "hello world" {
+		t.Fatalf("unexpected body written to client: %s", underlying.Body.String())
+	}
+
+	entry, ok := store.Get("k")
+	if !ok {
+		t.Fatalf("expected Finish to commit the spooled body")
+	}
+	if string(entry.Body) != "hello world" {
+		t.Fatalf("unexpected cached body: %s", entry.Body)
+	}
+}
+
+type recordingTestMetrics struct {
+	hits, misses, stores int
+}
+
+func (m *recordingTestMetrics) OnHit(key string, stale bool)            { m.hits++ }
+func (m *recordingTestMetrics) OnMiss(key string)                       { m.misses++ }
+func (m *recordingTestMetrics) OnStore(key string, bytes int64)         { m.stores++ }
+func (m *recordingTestMetrics) OnEvict(key string, bytes int64, reason string) {}
+func (m *recordingTestMetrics) OnRevalidate(key string, status int, dur time.Duration) {}
+
+func TestCacheMiddlewareReportsMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", Handler)
+
+	metrics := &recordingTestMetrics{}
+	config := Config{
+		DefaultTTL:   1 * time.Second,
+		DefaultSWR:   1500 * time.Millisecond,
+		KeyGenerator: DefaultKeyGenerator,
+		ShouldCache:  func(statusCode int) bool { return statusCode == http.StatusOK },
+		StripHeaders: stripHopByHop,
+		Metrics:      metrics,
+	}
+	client := NewCacheMiddleware(cache2.NewInMemoryQuotaLRU(2), &config)(mux)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	client.ServeHTTP(httptest.NewRecorder(), req)
+	client.ServeHTTP(httptest.NewRecorder(), req)
+
+	if metrics.misses != 1 {
+		t.Fatalf("expected 1 miss, got %d", metrics.misses)
+	}
+	if metrics.stores != 1 {
+		t.Fatalf("expected 1 store, got %d", metrics.stores)
+	}
+	if metrics.hits != 1 {
+		t.Fatalf("expected 1 hit, got %d", metrics.hits)
+	}
+}
@@ -0,0 +1,107 @@
+package capsulecache
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/spdeepak/capsulecache/cache"
+)
+
+// MethodPurge is the non-standard HTTP method conventionally used by caches
+// (Varnish, Squid, ...) for invalidation requests.
+const MethodPurge = "PURGE"
+
+// PurgeOnMutation returns middleware that watches non-GET/HEAD requests and,
+// once the handler completes successfully (2xx), derives invalidation tags
+// from the request via tagsFn and deletes every cached entry carrying them.
+// Mount it alongside NewCacheMiddleware so a POST/PUT/DELETE that mutates
+// "user:42" can evict every cached GET tagged with it.
+func PurgeOnMutation(store cache.Store, tagsFn func(*http.Request) []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			if request.Method == http.MethodGet || request.Method == http.MethodHead {
+				next.ServeHTTP(responseWriter, request)
+				return
+			}
+
+			responseRecorder := NewResponseRecorder(responseWriter, 0)
+			next.ServeHTTP(responseRecorder, request)
+			responseRecorder.Flush()
+
+			status := responseRecorder.StatusCode()
+			if status < http.StatusOK || status >= http.StatusMultipleChoices {
+				return
+			}
+
+			for _, tag := range tagsFn(request) {
+				if err := store.DeleteByTag(tag); err != nil {
+					slog.Error("Failed to purge cache by tag after mutation", slog.Any("tag", tag), slog.Any("error", err.Error()), slog.Any("request", request))
+				}
+			}
+		})
+	}
+}
+
+// purgeRequest is the JSON body accepted by NewPurgeHandler when the target
+// isn't supplied as a query parameter.
+type purgeRequest struct {
+	Key    string `json:"key"`
+	Prefix string `json:"prefix"`
+	Tag    string `json:"tag"`
+}
+
+// NewPurgeHandler returns an admin endpoint for active cache invalidation.
+// It accepts PURGE or DELETE requests naming a "key", "prefix", or "tag" as
+// a query parameter or a JSON body, so external systems (deploy hooks, admin
+// tools) can invalidate entries on demand. If more than one is given, only
+// the first of key, prefix, tag (in that order) is acted on.
+func NewPurgeHandler(store cache.Store) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.Method != MethodPurge && request.Method != http.MethodDelete {
+			responseWriter.Header().Set("Allow", MethodPurge+", "+http.MethodDelete)
+			http.Error(responseWriter, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key, prefix, tag := purgeTarget(request)
+
+		var err error
+		switch {
+		case key != "":
+			err = store.Delete(key)
+		case prefix != "":
+			err = store.Purge(prefix)
+		case tag != "":
+			err = store.DeleteByTag(tag)
+		default:
+			http.Error(responseWriter, "must specify one of key, prefix, or tag", http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// purgeTarget extracts the key/prefix/tag to invalidate from query
+// parameters, falling back to a JSON body if none were given.
+func purgeTarget(request *http.Request) (key, prefix, tag string) {
+	query := request.URL.Query()
+	key, prefix, tag = query.Get("key"), query.Get("prefix"), query.Get("tag")
+	if key != "" || prefix != "" || tag != "" {
+		return key, prefix, tag
+	}
+
+	if request.Body == nil {
+		return "", "", ""
+	}
+	var body purgeRequest
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		return "", "", ""
+	}
+	return body.Key, body.Prefix, body.Tag
+}
@@ -0,0 +1,142 @@
+package capsulecache
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/spdeepak/capsulecache/cache"
+)
+
+// StreamingRecorder tees a handler's response straight through to the real
+// client while simultaneously spooling it into a cache.BlobWriter, so a
+// response of any size can be cached without ever being buffered in full
+// (the role ResponseRecorder's MaxBodyBytes cap used to play). Whether and
+// how to cache is decided lazily, right before the first byte reaches the
+// client, via onHeader: at that point the status code and headers are
+// known but no body bytes have been produced yet. onHeader may return nil
+// to skip caching this response.
+type StreamingRecorder struct {
+	mu          sync.Mutex
+	underlying  http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+
+	onHeader    func(status int, header http.Header) cache.BlobWriter
+	onCommitErr func(error)
+	blob        cache.BlobWriter
+	aborted     bool
+}
+
+// NewStreamingRecorder returns a StreamingRecorder writing through to
+// responseWriter. onCommitErr, if non-nil, is called with any error from
+// committing the spooled body to the cache (e.g. to log it with request
+// context the recorder itself doesn't have).
+func NewStreamingRecorder(responseWriter http.ResponseWriter, onHeader func(status int, header http.Header) cache.BlobWriter, onCommitErr func(error)) *StreamingRecorder {
+	return &StreamingRecorder{
+		underlying:  responseWriter,
+		header:      make(http.Header),
+		status:      http.StatusOK,
+		onHeader:    onHeader,
+		onCommitErr: onCommitErr,
+	}
+}
+
+// Header implements http.ResponseWriter.
+func (r *StreamingRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *StreamingRecorder) WriteHeader(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.flushHeaderLocked()
+}
+
+// Write implements http.ResponseWriter, teeing p to the client and, once a
+// BlobWriter has been opened, to the cache.
+func (r *StreamingRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	if !r.wroteHeader {
+		r.flushHeaderLocked()
+	}
+	blob := r.blob
+	r.mu.Unlock()
+
+	n, err := r.underlying.Write(p)
+	if blob != nil && n > 0 {
+		if _, blobErr := blob.Write(p[:n]); blobErr != nil {
+			r.Abort()
+		}
+	}
+	return n, err
+}
+
+// flushHeaderLocked writes status+headers to the underlying writer and opens
+// the BlobWriter, if any. Callers must hold r.mu.
+func (r *StreamingRecorder) flushHeaderLocked() {
+	r.wroteHeader = true
+	for key, values := range r.header {
+		for _, value := range values {
+			r.underlying.Header().Add(key, value)
+		}
+	}
+	r.underlying.WriteHeader(r.status)
+	if r.onHeader != nil {
+		r.blob = r.onHeader(r.status, r.header.Clone())
+	}
+}
+
+// StatusCode returns the status code written so far (http.StatusOK if the
+// handler hasn't called WriteHeader yet, matching net/http's own default).
+func (r *StreamingRecorder) StatusCode() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// HeaderWritten reports whether any response bytes have reached the client
+// yet, so a recovered panic knows whether it's still safe to send an error
+// response instead.
+func (r *StreamingRecorder) HeaderWritten() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wroteHeader
+}
+
+// Finish must be called once the handler returns. It commits the spooled
+// body, if any, so it becomes visible to future Get/OpenReader calls.
+func (r *StreamingRecorder) Finish() {
+	r.mu.Lock()
+	if !r.wroteHeader {
+		r.flushHeaderLocked()
+	}
+	blob, aborted := r.blob, r.aborted
+	r.mu.Unlock()
+
+	if blob == nil || aborted {
+		return
+	}
+	if err := blob.Commit(); err != nil && r.onCommitErr != nil {
+		r.onCommitErr(err)
+	}
+}
+
+// Abort discards whatever has been spooled to the cache so far, e.g. after a
+// handler panic. It does not affect bytes already sent to the client. Safe
+// to call multiple times.
+func (r *StreamingRecorder) Abort() {
+	r.mu.Lock()
+	blob := r.blob
+	r.aborted = true
+	r.mu.Unlock()
+
+	if blob != nil {
+		_ = blob.Abort()
+	}
+}